@@ -0,0 +1,40 @@
+package assets
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"math/rand"
+	"path"
+	"strings"
+	"time"
+)
+
+// Default holds the stock covers used when a chapter has no cover.
+//
+//go:embed default
+var Default embed.FS
+
+// RandomCover returns the name and data of a random stock cover.
+func RandomCover() (string, []byte, error) {
+	files, err := fs.ReadDir(Default, "default")
+	if err != nil {
+		return "", nil, err
+	}
+	names := make([]string, 0)
+	for _, f := range files {
+		if !f.IsDir() &&
+			strings.HasSuffix(f.Name(), ".jpg") &&
+			strings.HasPrefix(f.Name(), "default_cover_") {
+			names = append(names, f.Name())
+		}
+	}
+	if len(names) == 0 {
+		return "", nil, errors.New("no default cover found")
+	}
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	name := names[r.Intn(len(names))]
+	data, err := Default.ReadFile(path.Join("default", name))
+	return name, data, err
+}
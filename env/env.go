@@ -8,44 +8,142 @@ import (
 )
 
 const (
-	DEFAULT_DOMAIN                  = ""
-	DEFAULT_COMIC_ID                = 0
-	DEFAULT_NETTRUYEN_DOMAIN        = "nettruyendie.com"
-	DEFAULT_NETTRUYEN_REFERER       = ""
-	DEFAULT_NETTRUYEN_CHAPTER_QUERY = "Comic/Services/ComicService.asmx/ProcessChapterList"
-	DEFAULT_QQTRUYEN_DOMAIN         = "truyenqqviet.com"
-	DEFAULT_QQTRUYEN_REFERER        = "https://truyenqqviet.com/"
-	DEFAULT_QQTRUYEN_CHAPTER_QUERY  = ""
-	DEFAULT_CRAWL_ALL               = false
-	DEFAULT_CRAWL_CHAPTERS          = ""
-	DEFAULT_CRAWL_WORKER            = 8
-	DEFAULT_DOWNLOAD_WORKER         = 1
-	DEFAULT_SLEEP                   = 2000
-	DEFAULT_COVER                   = ""
-	DEFAULT_TITLE                   = "Title"
-	DEFAULT_AUTHOR                  = "Unknown"
-	DEFAULT_CONVERT_FORMAT          = "EPUB"
-	DEFAULT_CONVERT_COMIC_ID        = ""
+	DEFAULT_DOMAIN                       = ""
+	DEFAULT_COMIC_ID                     = 0
+	DEFAULT_NETTRUYEN_DOMAIN             = "nettruyendie.com"
+	DEFAULT_NETTRUYEN_REFERER            = ""
+	DEFAULT_NETTRUYEN_CHAPTER_QUERY      = "Comic/Services/ComicService.asmx/ProcessChapterList"
+	DEFAULT_QQTRUYEN_DOMAIN              = "truyenqqviet.com"
+	DEFAULT_QQTRUYEN_REFERER             = "https://truyenqqviet.com/"
+	DEFAULT_QQTRUYEN_CHAPTER_QUERY       = ""
+	DEFAULT_CRAWL_ALL                    = false
+	DEFAULT_CRAWL_CHAPTERS               = ""
+	DEFAULT_CRAWL_WORKER                 = 8
+	DEFAULT_DOWNLOAD_WORKER              = 1
+	DEFAULT_SLEEP                        = 2000
+	DEFAULT_COVER                        = ""
+	DEFAULT_TITLE                        = "Title"
+	DEFAULT_AUTHOR                       = "Unknown"
+	DEFAULT_CONVERT_FORMAT               = "EPUB"
+	DEFAULT_CONVERT_COMIC_ID             = ""
+	DEFAULT_NETTRUYEN_CHECK_COMIC_ID     = 0
+	DEFAULT_QQTRUYEN_CHECK_CHAPTER_QUERY = ""
+	DEFAULT_NETTRUYEN_CHECK_CHAPTER      = ""
+	DEFAULT_QQTRUYEN_CHECK_CHAPTER       = ""
+	DEFAULT_PREFERRED_GROUP              = ""
+	DEFAULT_WEBTOON                      = false
+	DEFAULT_WEBTOON_RATIO                = 1.4
+	DEFAULT_WEBTOON_MIN_FRAGMENT         = 0.25
+	DEFAULT_WEBTOON_STITCH               = true
+	DEFAULT_DEVICE_PROFILE               = ""
+	DEFAULT_GRAYSCALE                    = "AUTO"
+	DEFAULT_GAMMA                        = 1.0
+	DEFAULT_CONTRAST                     = 0.0
+	DEFAULT_QUALITY                      = 85
+	DEFAULT_RTL                          = false
+	DEFAULT_SPREAD_POLICY                = "rotate"
+	DEFAULT_AUTO_CROP                    = false
+	DEFAULT_CROP_TOLERANCE               = 16.0
+	DEFAULT_CROP_LIMIT                   = 0.15
+	DEFAULT_WATERMARK_TOP                = 0
+	DEFAULT_WATERMARK_BOTTOM             = 0
+	DEFAULT_AD_DETECT                    = false
+	DEFAULT_AD_MIN_CHAPTERS              = 3
+	DEFAULT_AD_HASH_DISTANCE             = 4
+	DEFAULT_AD_BLOCKLIST                 = "blocklist.txt"
+	DEFAULT_PIPELINE_WORKER              = 2
+	DEFAULT_EPUB_ENCODING                = "original"
+	DEFAULT_PDF_ENCODING                 = "original"
+	DEFAULT_CBZ_ENCODING                 = "original"
+	DEFAULT_EPUB_LAYOUT                  = "reflow"
+	DEFAULT_PANEL_VIEW                   = true
+	DEFAULT_TEMPLATE_DIR                 = ""
+	DEFAULT_COVER_STRATEGY               = "random"
+	DEFAULT_COVER_FONT                   = ""
+	DEFAULT_LANGUAGE                     = "vi"
+	DEFAULT_PUBLISHER                    = ""
+	DEFAULT_DESCRIPTION                  = ""
+	DEFAULT_SUBJECTS                     = ""
+	DEFAULT_CONVERT_WORKER               = 2
+	DEFAULT_CONVERT_INCREMENTAL          = true
+	DEFAULT_HTML_MODE                    = "scroll"
+	DEFAULT_HTML_ENCODING                = "original"
+	DEFAULT_SERVE_ADDR                   = ":8080"
+	DEFAULT_JOB_DIR                      = "jobs"
+	DEFAULT_JOB_WORKER                   = 1
+	DEFAULT_API_TOKEN                    = ""
+	DEFAULT_LIBRARY_DIR                  = "library"
+	DEFAULT_LIBRARY_LINK                 = "copy"
+	DEFAULT_LIBRARY_FORMAT               = "CBZ"
 )
 
 var (
-	Domain                string
-	ComicId               int
-	NettruyenDomain       string
-	NettruyenReferer      string
-	NettruyenChapterQuery string
-	QqtruyenDomain        string
-	QqtruyenReferer       string
-	QqtruyenChapterQuery  string
-	CrawlAll              bool
-	CrawlChapters         string
-	CrawlWorker           int
-	DownloadWorker        int
-	Sleep                 int
-	Cover                 string
-	Title                 string
-	Author                string
-	ConvertFormat         string
+	Domain                    string
+	ComicId                   int
+	NettruyenDomain           string
+	NettruyenReferer          string
+	NettruyenChapterQuery     string
+	QqtruyenDomain            string
+	QqtruyenReferer           string
+	QqtruyenChapterQuery      string
+	CrawlAll                  bool
+	CrawlChapters             string
+	CrawlWorker               int
+	DownloadWorker            int
+	Sleep                     int
+	Cover                     string
+	Title                     string
+	Author                    string
+	ConvertFormat             string
+	NettruyenCheckComicId     int
+	QqtruyenCheckChapterQuery string
+	NettruyenCheckChapter     string
+	QqtruyenCheckChapter      string
+	PreferredGroup            string
+	Webtoon                   bool
+	WebtoonRatio              float64
+	WebtoonMinFragment        float64
+	WebtoonStitch             bool
+	DeviceProfile             string
+	Grayscale                 string
+	Gamma                     float64
+	Contrast                  float64
+	Quality                   int
+	RTL                       bool
+	SpreadPolicy              string
+	AutoCrop                  bool
+	CropTolerance             float64
+	CropLimit                 float64
+	WatermarkTop              int
+	WatermarkBottom           int
+	AdDetect                  bool
+	AdMinChapters             int
+	AdHashDistance            int
+	AdBlocklist               string
+	PipelineWorker            int
+	EpubEncoding              string
+	PdfEncoding               string
+	CbzEncoding               string
+	EpubLayout                string
+	PanelView                 bool
+	TemplateDir               string
+	CoverStrategy             string
+	CoverFont                 string
+	Language                  string
+	Publisher                 string
+	Description               string
+	Subjects                  string
+	ConvertWorker             int
+	ConvertIncremental        bool
+	HtmlMode                  string
+	HtmlEncoding              string
+	ServeAddr                 string
+	JobDir                    string
+	JobWorker                 int
+	ApiToken                  string
+	LibraryDir                string
+	LibraryLink               string
+	LibraryFormat             string
 )
 
 func Init() error {
@@ -156,6 +254,300 @@ func Init() error {
 		ConvertFormat = DEFAULT_CONVERT_FORMAT
 	}
 
+	if nettruyenCheckComicId, ok := env["NETTRUYEN_CHECK_COMIC_ID"]; ok {
+		NettruyenCheckComicId, _ = strconv.Atoi(nettruyenCheckComicId)
+	} else {
+		NettruyenCheckComicId = DEFAULT_NETTRUYEN_CHECK_COMIC_ID
+	}
+
+	if qqtruyenCheckChapterQuery, ok := env["QQTRUYEN_CHECK_CHAPTER_QUERY"]; ok {
+		QqtruyenCheckChapterQuery = qqtruyenCheckChapterQuery
+	} else {
+		QqtruyenCheckChapterQuery = DEFAULT_QQTRUYEN_CHECK_CHAPTER_QUERY
+	}
+
+	if nettruyenCheckChapter, ok := env["NETTRUYEN_CHECK_CHAPTER"]; ok {
+		NettruyenCheckChapter = nettruyenCheckChapter
+	} else {
+		NettruyenCheckChapter = DEFAULT_NETTRUYEN_CHECK_CHAPTER
+	}
+
+	if qqtruyenCheckChapter, ok := env["QQTRUYEN_CHECK_CHAPTER"]; ok {
+		QqtruyenCheckChapter = qqtruyenCheckChapter
+	} else {
+		QqtruyenCheckChapter = DEFAULT_QQTRUYEN_CHECK_CHAPTER
+	}
+
+	if preferredGroup, ok := env["PREFERRED_GROUP"]; ok {
+		PreferredGroup = preferredGroup
+	} else {
+		PreferredGroup = DEFAULT_PREFERRED_GROUP
+	}
+
+	if webtoon, ok := env["WEBTOON"]; ok {
+		Webtoon, _ = strconv.ParseBool(webtoon)
+	} else {
+		Webtoon = DEFAULT_WEBTOON
+	}
+
+	if webtoonRatio, ok := env["WEBTOON_RATIO"]; ok {
+		WebtoonRatio, _ = strconv.ParseFloat(webtoonRatio, 64)
+	} else {
+		WebtoonRatio = DEFAULT_WEBTOON_RATIO
+	}
+
+	if webtoonMinFragment, ok := env["WEBTOON_MIN_FRAGMENT"]; ok {
+		WebtoonMinFragment, _ = strconv.ParseFloat(webtoonMinFragment, 64)
+	} else {
+		WebtoonMinFragment = DEFAULT_WEBTOON_MIN_FRAGMENT
+	}
+
+	if webtoonStitch, ok := env["WEBTOON_STITCH"]; ok {
+		WebtoonStitch, _ = strconv.ParseBool(webtoonStitch)
+	} else {
+		WebtoonStitch = DEFAULT_WEBTOON_STITCH
+	}
+
+	if deviceProfile, ok := env["DEVICE_PROFILE"]; ok {
+		DeviceProfile = deviceProfile
+	} else {
+		DeviceProfile = DEFAULT_DEVICE_PROFILE
+	}
+
+	if grayscale, ok := env["GRAYSCALE"]; ok {
+		Grayscale = grayscale
+	} else {
+		Grayscale = DEFAULT_GRAYSCALE
+	}
+
+	if gamma, ok := env["GAMMA"]; ok {
+		Gamma, _ = strconv.ParseFloat(gamma, 64)
+	} else {
+		Gamma = DEFAULT_GAMMA
+	}
+
+	if contrast, ok := env["CONTRAST"]; ok {
+		Contrast, _ = strconv.ParseFloat(contrast, 64)
+	} else {
+		Contrast = DEFAULT_CONTRAST
+	}
+
+	if quality, ok := env["QUALITY"]; ok {
+		Quality, _ = strconv.Atoi(quality)
+	} else {
+		Quality = DEFAULT_QUALITY
+	}
+
+	if rtl, ok := env["RTL"]; ok {
+		RTL, _ = strconv.ParseBool(rtl)
+	} else {
+		RTL = DEFAULT_RTL
+	}
+
+	if spreadPolicy, ok := env["SPREAD_POLICY"]; ok {
+		SpreadPolicy = spreadPolicy
+	} else {
+		SpreadPolicy = DEFAULT_SPREAD_POLICY
+	}
+
+	if autoCrop, ok := env["AUTO_CROP"]; ok {
+		AutoCrop, _ = strconv.ParseBool(autoCrop)
+	} else {
+		AutoCrop = DEFAULT_AUTO_CROP
+	}
+
+	if cropTolerance, ok := env["CROP_TOLERANCE"]; ok {
+		CropTolerance, _ = strconv.ParseFloat(cropTolerance, 64)
+	} else {
+		CropTolerance = DEFAULT_CROP_TOLERANCE
+	}
+
+	if cropLimit, ok := env["CROP_LIMIT"]; ok {
+		CropLimit, _ = strconv.ParseFloat(cropLimit, 64)
+	} else {
+		CropLimit = DEFAULT_CROP_LIMIT
+	}
+
+	if watermarkTop, ok := env["WATERMARK_TOP"]; ok {
+		WatermarkTop, _ = strconv.Atoi(watermarkTop)
+	} else {
+		WatermarkTop = DEFAULT_WATERMARK_TOP
+	}
+
+	if watermarkBottom, ok := env["WATERMARK_BOTTOM"]; ok {
+		WatermarkBottom, _ = strconv.Atoi(watermarkBottom)
+	} else {
+		WatermarkBottom = DEFAULT_WATERMARK_BOTTOM
+	}
+
+	if adDetect, ok := env["AD_DETECT"]; ok {
+		AdDetect, _ = strconv.ParseBool(adDetect)
+	} else {
+		AdDetect = DEFAULT_AD_DETECT
+	}
+
+	if adMinChapters, ok := env["AD_MIN_CHAPTERS"]; ok {
+		AdMinChapters, _ = strconv.Atoi(adMinChapters)
+	} else {
+		AdMinChapters = DEFAULT_AD_MIN_CHAPTERS
+	}
+
+	if adHashDistance, ok := env["AD_HASH_DISTANCE"]; ok {
+		AdHashDistance, _ = strconv.Atoi(adHashDistance)
+	} else {
+		AdHashDistance = DEFAULT_AD_HASH_DISTANCE
+	}
+
+	if adBlocklist, ok := env["AD_BLOCKLIST"]; ok {
+		AdBlocklist = adBlocklist
+	} else {
+		AdBlocklist = DEFAULT_AD_BLOCKLIST
+	}
+
+	if pipelineWorker, ok := env["PIPELINE_WORKER"]; ok {
+		PipelineWorker, _ = strconv.Atoi(pipelineWorker)
+	} else {
+		PipelineWorker = DEFAULT_PIPELINE_WORKER
+	}
+
+	if epubEncoding, ok := env["EPUB_ENCODING"]; ok {
+		EpubEncoding = epubEncoding
+	} else {
+		EpubEncoding = DEFAULT_EPUB_ENCODING
+	}
+
+	if pdfEncoding, ok := env["PDF_ENCODING"]; ok {
+		PdfEncoding = pdfEncoding
+	} else {
+		PdfEncoding = DEFAULT_PDF_ENCODING
+	}
+
+	if cbzEncoding, ok := env["CBZ_ENCODING"]; ok {
+		CbzEncoding = cbzEncoding
+	} else {
+		CbzEncoding = DEFAULT_CBZ_ENCODING
+	}
+
+	if epubLayout, ok := env["EPUB_LAYOUT"]; ok {
+		EpubLayout = epubLayout
+	} else {
+		EpubLayout = DEFAULT_EPUB_LAYOUT
+	}
+
+	if panelView, ok := env["PANEL_VIEW"]; ok {
+		PanelView, _ = strconv.ParseBool(panelView)
+	} else {
+		PanelView = DEFAULT_PANEL_VIEW
+	}
+
+	if templateDir, ok := env["TEMPLATE_DIR"]; ok {
+		TemplateDir = templateDir
+	} else {
+		TemplateDir = DEFAULT_TEMPLATE_DIR
+	}
+
+	if coverStrategy, ok := env["COVER_STRATEGY"]; ok {
+		CoverStrategy = coverStrategy
+	} else {
+		CoverStrategy = DEFAULT_COVER_STRATEGY
+	}
+
+	if coverFont, ok := env["COVER_FONT"]; ok {
+		CoverFont = coverFont
+	} else {
+		CoverFont = DEFAULT_COVER_FONT
+	}
+
+	if language, ok := env["LANGUAGE"]; ok {
+		Language = language
+	} else {
+		Language = DEFAULT_LANGUAGE
+	}
+
+	if publisher, ok := env["PUBLISHER"]; ok {
+		Publisher = publisher
+	} else {
+		Publisher = DEFAULT_PUBLISHER
+	}
+
+	if description, ok := env["DESCRIPTION"]; ok {
+		Description = description
+	} else {
+		Description = DEFAULT_DESCRIPTION
+	}
+
+	if subjects, ok := env["SUBJECTS"]; ok {
+		Subjects = subjects
+	} else {
+		Subjects = DEFAULT_SUBJECTS
+	}
+
+	if convertWorker, ok := env["CONVERT_WORKER"]; ok {
+		ConvertWorker, _ = strconv.Atoi(convertWorker)
+	} else {
+		ConvertWorker = DEFAULT_CONVERT_WORKER
+	}
+
+	if convertIncremental, ok := env["CONVERT_INCREMENTAL"]; ok {
+		ConvertIncremental, _ = strconv.ParseBool(convertIncremental)
+	} else {
+		ConvertIncremental = DEFAULT_CONVERT_INCREMENTAL
+	}
+
+	if htmlMode, ok := env["HTML_MODE"]; ok {
+		HtmlMode = htmlMode
+	} else {
+		HtmlMode = DEFAULT_HTML_MODE
+	}
+
+	if htmlEncoding, ok := env["HTML_ENCODING"]; ok {
+		HtmlEncoding = htmlEncoding
+	} else {
+		HtmlEncoding = DEFAULT_HTML_ENCODING
+	}
+
+	if serveAddr, ok := env["SERVE_ADDR"]; ok {
+		ServeAddr = serveAddr
+	} else {
+		ServeAddr = DEFAULT_SERVE_ADDR
+	}
+
+	if jobDir, ok := env["JOB_DIR"]; ok {
+		JobDir = jobDir
+	} else {
+		JobDir = DEFAULT_JOB_DIR
+	}
+
+	if jobWorker, ok := env["JOB_WORKER"]; ok {
+		JobWorker, _ = strconv.Atoi(jobWorker)
+	} else {
+		JobWorker = DEFAULT_JOB_WORKER
+	}
+
+	if apiToken, ok := env["API_TOKEN"]; ok {
+		ApiToken = apiToken
+	} else {
+		ApiToken = DEFAULT_API_TOKEN
+	}
+
+	if libraryDir, ok := env["LIBRARY_DIR"]; ok {
+		LibraryDir = libraryDir
+	} else {
+		LibraryDir = DEFAULT_LIBRARY_DIR
+	}
+
+	if libraryLink, ok := env["LIBRARY_LINK"]; ok {
+		LibraryLink = libraryLink
+	} else {
+		LibraryLink = DEFAULT_LIBRARY_LINK
+	}
+
+	if libraryFormat, ok := env["LIBRARY_FORMAT"]; ok {
+		LibraryFormat = libraryFormat
+	} else {
+		LibraryFormat = DEFAULT_LIBRARY_FORMAT
+	}
+
 	return nil
 }
 
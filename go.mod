@@ -1,14 +1,17 @@
 module comic-crawler
 
-go 1.22.1
+go 1.22.2
 
 require (
+	github.com/HugoSmits86/nativewebp v0.9.3
 	github.com/anthonynsimon/bild v0.13.0
 	github.com/go-pdf/fpdf v0.9.0
 	github.com/go-shiori/go-epub v1.2.1
 	github.com/gocolly/colly v1.2.0
 	github.com/joho/godotenv v1.5.1
 	github.com/vukyn/kuery v1.2.9
+	golang.org/x/image v0.16.0
+	golang.org/x/text v0.15.0
 )
 
 require (
@@ -26,9 +29,7 @@ require (
 	github.com/saintfish/chardet v0.0.0-20120816061221-3af4cd4741ca // indirect
 	github.com/temoto/robotstxt v1.1.1 // indirect
 	github.com/vincent-petithory/dataurl v1.0.0 // indirect
-	golang.org/x/image v0.16.0 // indirect
 	golang.org/x/net v0.19.0 // indirect
-	golang.org/x/text v0.15.0 // indirect
 	google.golang.org/appengine v1.6.6 // indirect
 	google.golang.org/protobuf v1.24.0 // indirect
 )
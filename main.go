@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"math/rand"
+	"io"
+	"net/http"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -11,9 +16,20 @@ import (
 
 	"comic-crawler/env"
 	"comic-crawler/service"
+	"comic-crawler/service/batch"
+	"comic-crawler/service/cover"
 	"comic-crawler/service/crawler"
+	"comic-crawler/service/dedupe"
+	"comic-crawler/service/doctor"
 	"comic-crawler/service/downloader"
 	"comic-crawler/service/epub"
+	"comic-crawler/service/export"
+	"comic-crawler/service/imaging"
+	"comic-crawler/service/jobs"
+	"comic-crawler/service/library"
+	"comic-crawler/service/pathname"
+	"comic-crawler/service/server"
+	"comic-crawler/service/web"
 
 	"github.com/gocolly/colly"
 	"github.com/vukyn/kuery/file"
@@ -43,14 +59,89 @@ func init() {
 
 func main() {
 	timeStart := time.Now()
-	// crawl()
-	convert()
+	switch command() {
+	case "crawl":
+		err := crawl(context.Background(), crawlOption{
+			Domain:   env.Domain,
+			ComicId:  env.ComicId,
+			Query:    env.QqtruyenChapterQuery,
+			CrawlAll: env.CrawlAll,
+			Chapters: env.CrawlChapters,
+		})
+		if err != nil {
+			log.Errorf("Failed to crawl: %v", err)
+		}
+	case "record":
+		record()
+	case "serve":
+		if err := serve(); err != nil {
+			log.Errorf("Server stopped: %v", err)
+			os.Exit(1)
+		}
+	case "export":
+		if err := exportLibrary(); err != nil {
+			log.Errorf("Failed to export: %v", err)
+			os.Exit(1)
+		}
+	case "doctor", "check-sources":
+		if err := checkSources(); err != nil {
+			log.Errorf("Source check failed: %v", err)
+			os.Exit(1)
+		}
+	default:
+		force, dryRun, err := convertFlags()
+		if err != nil {
+			log.Errorf("Invalid convert flags: %v", err)
+			return
+		}
+		err = convert(context.Background(), convertOption{
+			Domain:  env.Domain,
+			ComicId: env.ComicId,
+			Formats: env.ConvertFormat,
+			Series:  envSeries(),
+			Force:   force,
+			DryRun:  dryRun,
+		})
+		if err != nil {
+			log.Errorf("Failed to convert: %v", err)
+		}
+	}
 	log.Infof("Done for %.2fs!", time.Since(timeStart).Seconds())
 }
 
-func crawl() {
-	domain := env.Domain
-	comicId := env.ComicId
+// command returns the command passed as first argument, default to convert.
+func command() string {
+	if len(os.Args) < 2 {
+		return "convert"
+	}
+	return strings.ToLower(os.Args[1])
+}
+
+// args returns the arguments following the command.
+func args() []string {
+	if len(os.Args) < 3 {
+		return nil
+	}
+	return os.Args[2:]
+}
+
+type crawlOption struct {
+	Domain  string
+	ComicId int
+	// Chapter list url of sources without comic ids (qqtruyen)
+	Query    string
+	CrawlAll bool
+	// Chapters to crawl when not crawling all: a range 1-10 or a list 1,2,5
+	Chapters string
+	Log      logger
+}
+
+func crawl(ctx context.Context, opt crawlOption) error {
+	// Messages also go to the job log
+	log := opt.Log
+	domain := opt.Domain
+	comicId := opt.ComicId
+	series := crawler.Series{ComicId: comicId, Query: opt.Query}
 
 	// Init crawler
 	log.Infof("Starting crawler...")
@@ -64,21 +155,27 @@ func crawl() {
 	})
 
 	log.Infof("Trying to get list of chapters...")
-	chapters, err := crawler.CrawlChapter(c, domain)
+	chapters, err := crawler.CrawlChapter(c, domain, series)
 	if err != nil {
-		log.Errorf("Failed to get list of chapters: %v", err)
-		return
+		return fmt.Errorf("failed to get list of chapters: %w", err)
 	}
 
+	downloadSeriesCover(c, domain, series, chapters)
+
 	// Init downloader
 	log.Infof("Starting downloader...")
 	downloadWorker := env.DownloadWorker
 	log.Infof("Number of download workers: %d", downloadWorker)
 
+	blocklist, err := dedupe.LoadBlocklist(env.AdBlocklist)
+	if err != nil {
+		return fmt.Errorf("failed to load blocklist: %w", err)
+	}
+
 	fmt.Println("-----------------------------------")
 
-	isCrawlAll := env.CrawlAll
-	crawlChaptersEnv := env.CrawlChapters
+	isCrawlAll := opt.CrawlAll
+	crawlChaptersEnv := opt.Chapters
 	crawlChapters := make([]string, 0)
 	if crawlChaptersEnv != "" {
 		if strings.Contains(crawlChaptersEnv, ",") {
@@ -88,8 +185,7 @@ func crawl() {
 			start, _ := strconv.Atoi(crawlRange[0])
 			end, _ := strconv.Atoi(crawlRange[1])
 			if start > end {
-				log.Errorf("Invalid range: %s", crawlChaptersEnv)
-				return
+				return fmt.Errorf("invalid range: %s", crawlChaptersEnv)
 			}
 			for i := start; i <= end; i++ {
 				crawlChapters = append(crawlChapters, fmt.Sprint(i))
@@ -99,16 +195,33 @@ func crawl() {
 		}
 	}
 
+	// Chapter folders are named after sanitized chapter names
+	names, err := pathname.LoadNames(fmt.Sprintf("out/%s/%d", getWebsiteName(domain), comicId))
+	if err != nil {
+		return fmt.Errorf("failed to load chapter names: %w", err)
+	}
+
 	for _, chapter := range chapters {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if !isCrawlAll {
 			if isAny := query.AnyFunc(crawlChapters, func(i string) bool {
+				if number, err := strconv.ParseFloat(strings.TrimSpace(i), 64); err == nil && number == chapter.Number {
+					return true
+				}
 				return "Chapter "+i == chapter.Name || "Chương "+i == chapter.Name || i == chapter.Name
 			}); !isAny {
 				log.Warnf("Skipping chapter %s...", chapter.Name)
 				continue
 			}
 		}
-		if ok, err := skipChapter(domain, chapter.Name, comicId); err != nil {
+		folderName, err := names.Folder(chapter.Name)
+		if err != nil {
+			log.Errorf("Failed to name folder of chapter %s: %v", chapter.Name, err)
+			continue
+		}
+		if ok, err := skipChapter(domain, folderName, comicId); err != nil {
 			log.Errorf("Failed to skip chapter %s: %v", chapter.Name, err)
 			continue
 		} else if ok {
@@ -126,7 +239,7 @@ func crawl() {
 		var wg sync.WaitGroup
 		jobs := make(chan URL) // Channel for sending URLs to download jobs
 		wg.Add(len(urls))      // Set the wait group size to the number of URLs
-		folder := getFolderPath(domain, chapter.Name, comicId)
+		folder := getFolderPath(domain, folderName, comicId)
 		log.Infof("Creating folder %s", folder)
 		if err := service.OverwriteFolder(folder); err != nil {
 			log.Errorf("Failed to overwrite folder %s: %v", folder, err)
@@ -138,11 +251,19 @@ func crawl() {
 			workerId := i + 1
 			go func(workerId int) {
 				for job := range jobs {
-					if job.Url != "" {
+					// Canceled crawls drain the remaining urls
+					if job.Url != "" && ctx.Err() == nil {
 						// Download image
 						dest := fmt.Sprintf("%s%d.jpg", folder, job.Id)
 						if err := downloader.DownloadImg(workerId, job.Url, domain, dest); err != nil {
 							log.Errorf("Failed to download image %s: %v", job.Url, err)
+						} else if blocked, err := dedupe.IsBlocked(dest, blocklist, env.AdHashDistance); err != nil {
+							log.Errorf("Failed to check image %s: %v", job.Url, err)
+						} else if blocked {
+							log.Warnf("Removing blocklisted image %s", job.Url)
+							if err := os.Remove(dest); err != nil {
+								log.Errorf("Failed to remove image %s: %v", dest, err)
+							}
 						} else {
 							log.Infof("Downloaded %s", job.Url)
 						}
@@ -165,68 +286,723 @@ func crawl() {
 
 		// Wait for all download jobs to finish
 		wg.Wait()
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
 		sleep()
 	}
+	return nil
+}
+
+// downloadSeriesCover saves the series cover next to the chapters, once.
+func downloadSeriesCover(c *colly.Collector, domain string, series crawler.Series, chapters []crawler.Chapter) {
+	dest := fmt.Sprintf("out/%s/%d/%s", getWebsiteName(domain), series.ComicId, cover.SeriesFile)
+	if _, err := os.Stat(dest); err == nil {
+		return
+	}
+	url, err := crawler.CrawlCover(c, domain, series, chapters)
+	if err != nil {
+		log.Warnf("Failed to find series cover: %v", err)
+		return
+	}
+	if err := service.CreateFilePath(dest); err != nil {
+		log.Warnf("Failed to create folder for %s: %v", dest, err)
+		return
+	}
+	if err := downloader.DownloadImg(0, url, domain, dest); err != nil {
+		log.Warnf("Failed to download series cover %s: %v", url, err)
+		os.Remove(dest)
+	}
 }
 
-func convert() {
+func record() {
 	domain := env.Domain
-	comicId := env.ComicId
-	convertFormat := env.ConvertFormat
+
+	dir := fmt.Sprintf("service/crawler/testdata/%s", getWebsiteName(domain))
+	if args := args(); len(args) > 0 {
+		dir = args[0]
+	}
+
+	log.Infof("Recording fixtures into %s...", dir)
+	c := colly.NewCollector(
+		colly.AllowedDomains(domain, "www."+domain),
+	)
+	if err := crawler.Record(c, domain, crawler.Series{ComicId: env.ComicId, Query: env.QqtruyenChapterQuery}, dir); err != nil {
+		log.Errorf("Failed to record fixtures: %v", err)
+		return
+	}
+}
+
+// serve exposes the downloaded comics and the job API over HTTP until
+// interrupted.
+func serve() error {
+	mux := http.NewServeMux()
+	// Jobs run crawls on the server, only with a token
+	if env.ApiToken != "" {
+		queue, err := jobs.Open(env.JobDir, env.JobWorker, runJob)
+		if err != nil {
+			return fmt.Errorf("failed to open job queue: %w", err)
+		}
+		defer queue.Close()
+		api := jobs.Handler(queue, env.ApiToken)
+		mux.Handle("/api/jobs", api)
+		mux.Handle("/api/jobs/", api)
+	} else {
+		log.Warnf("API_TOKEN is not set, the job API is disabled")
+	}
+
+	titles := map[string]string{
+		fmt.Sprintf("%s/%d", getWebsiteName(env.Domain), env.ComicId): env.Title,
+	}
+	mux.Handle("/", server.New(server.Option{Root: "out", Titles: titles}))
+	log.Infof("Serving library on %s", env.ServeAddr)
+	return http.ListenAndServe(env.ServeAddr, mux)
+}
+
+// runJob runs a job submitted through the API, logging to its log file.
+func runJob(ctx context.Context, job jobs.Job, w io.Writer) error {
+	domain, err := sourceDomain(job.Source)
+	if err != nil {
+		return err
+	}
+	logger := logger{job: w}
+	switch job.Kind {
+	case jobs.Crawl:
+		chapters := strings.TrimSpace(job.Chapters)
+		return crawl(ctx, crawlOption{
+			Domain:   domain,
+			ComicId:  job.ComicId,
+			Query:    job.Query,
+			CrawlAll: chapters == "" || strings.EqualFold(chapters, "all"),
+			Chapters: chapters,
+			Log:      logger,
+		})
+	case jobs.Convert:
+		formats := job.Formats
+		if formats == "" {
+			formats = env.ConvertFormat
+		}
+		return convert(ctx, convertOption{
+			Domain:  domain,
+			ComicId: job.ComicId,
+			Formats: formats,
+			Series: series{
+				Title:       job.Title,
+				Author:      job.Author,
+				Description: job.Description,
+				Subjects:    job.Subjects,
+			},
+			Force: job.Force,
+			Log:   logger,
+		})
+	default:
+		return fmt.Errorf("unknown job kind %q", job.Kind)
+	}
+}
+
+// sourceDomain returns the domain of a source given by site name or domain.
+func sourceDomain(source string) (string, error) {
+	source = strings.ToLower(strings.TrimSpace(source))
+	for _, domain := range []string{env.NettruyenDomain, env.QqtruyenDomain} {
+		if source == domain || source == "www."+domain || source == getWebsiteName(domain) {
+			return domain, nil
+		}
+	}
+	return "", fmt.Errorf("source not supported: %s", source)
+}
+
+// logger logs to the console and, for jobs, to the job log.
+type logger struct {
+	job io.Writer
+}
+
+func (l logger) Infof(format string, args ...any) {
+	log.Infof(format, args...)
+	l.write("INFO", format, args...)
+}
+
+func (l logger) Warnf(format string, args ...any) {
+	log.Warnf(format, args...)
+	l.write("WARN", format, args...)
+}
+
+func (l logger) Errorf(format string, args ...any) {
+	log.Errorf(format, args...)
+	l.write("ERROR", format, args...)
+}
+
+func (l logger) write(level, format string, args ...any) {
+	if l.job != nil {
+		fmt.Fprintf(l.job, "%s %s %s\n", time.Now().Format(time.DateTime), level, fmt.Sprintf(format, args...))
+	}
+}
+
+// exportLibrary places the converted chapters in a media library folder
+// (LIBRARY_DIR or the first argument) laid out for Komga and Kavita.
+func exportLibrary() error {
+	link, err := export.ParseLink(env.LibraryLink)
+	if err != nil {
+		return err
+	}
+	dir := env.LibraryDir
+	if args := args(); len(args) > 0 {
+		dir = args[0]
+	}
+	formats := make([]string, 0)
+	for _, format := range strings.Split(env.LibraryFormat, ",") {
+		if format = strings.ToLower(strings.TrimSpace(format)); format != "" {
+			formats = append(formats, format)
+		}
+	}
+
+	comicPath := fmt.Sprintf("out/%s/%d", getWebsiteName(env.Domain), env.ComicId)
+	log.Infof("Exporting %s to %s...", comicPath, dir)
+	placed, err := export.Export(comicPath, export.Option{
+		Library:     dir,
+		Series:      env.Title,
+		ComicId:     strconv.Itoa(env.ComicId),
+		Publisher:   publisher(env.Domain),
+		Description: env.Description,
+		Formats:     formats,
+		Link:        link,
+	})
+	for _, path := range placed {
+		log.Infof("Exported %s", path)
+	}
+	if err != nil {
+		return err
+	}
+	log.Infof("Exported %d files", len(placed))
+	return nil
+}
+
+func checkSources() error {
+	log.Infof("Checking sources...")
+	results := make([]doctor.Result, 0)
+	for _, source := range doctor.Sources() {
+		log.Infof("Checking %s...", source.Name)
+		results = append(results, doctor.Check(source))
+	}
+
+	fmt.Println("-----------------------------------")
+	return doctor.Report(results)
+}
+
+type convertOption struct {
+	Domain  string
+	ComicId int
+	// Comma separated output formats
+	Formats string
+	Series  series
+	// Rebuild outputs which are up to date
+	Force bool
+	// List the outputs to rebuild without converting
+	DryRun bool
+	Log    logger
+}
+
+func convert(ctx context.Context, opt convertOption) error {
+	// Messages also go to the job log
+	log := opt.Log
+	domain := opt.Domain
+	comicId := opt.ComicId
+	convertFormat := opt.Formats
+	meta := opt.Series
+	// CONVERT_INCREMENTAL=FALSE rebuilds every output like --force
+	force, dryRun := opt.Force || !env.ConvertIncremental, opt.DryRun
 
 	comicPath := fmt.Sprintf("out/%s/%d", getWebsiteName(domain), comicId)
-	files, err := os.ReadDir(comicPath)
+	chapters, err := library.Chapters(comicPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			log.Errorf("Comic not found")
-			return
+			return errors.New("comic not found")
+		}
+		return fmt.Errorf("failed to read comic folder: %w", err)
+	}
+
+	names, err := pathname.LoadNames(comicPath)
+	if err != nil {
+		return fmt.Errorf("failed to load chapter names: %w", err)
+	}
+
+	skip := make(dedupe.Skip)
+	if env.AdDetect {
+		log.Infof("Detecting ad and duplicate pages...")
+		var removed []dedupe.Removed
+		adOpt := adOption()
+		adOpt.DryRun = dryRun
+		skip, removed, err = dedupe.Scan(comicPath, chapters, adOpt)
+		if err != nil {
+			return fmt.Errorf("failed to detect ad pages: %w", err)
+		}
+		for _, r := range removed {
+			log.Warnf("Removing %s/%s (%s): %s", r.Chapter, r.Page, r.Hash, r.Reason)
+		}
+		if dryRun {
+			log.Infof("Would remove %d pages", len(removed))
+		} else {
+			log.Infof("Removed %d pages, see %s/%s", len(removed), comicPath, dedupe.ReportFile)
 		}
-		log.Errorf("Failed to read comic folder: %v", err)
-		return
 	}
 
+	strategy, err := cover.ParseStrategy(env.CoverStrategy)
+	if err != nil {
+		return fmt.Errorf("invalid cover option: %w", err)
+	}
+
+	failed := make([]error, 0)
 	if convertFormat != "" {
 		log.Infof("Converting...")
+		log.Infof("Number of convert workers: %d", env.ConvertWorker)
 		convertList := strings.Split(convertFormat, ",")
 
-		wg := sync.WaitGroup{}
 		for _, format := range convertList {
-			switch strings.ToUpper(format) {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			format = strings.ToUpper(strings.TrimSpace(format))
+			cfg, err := pipelineConfig(format)
+			if err != nil {
+				log.Errorf("Invalid %s option: %v", format, err)
+				failed = append(failed, fmt.Errorf("%s: %w", format, err))
+				continue
+			}
+			pipeline := imaging.NewPipeline(cfg)
+			var convertChapter func(chapterPath, chapter string) error
+			switch format {
 			case "PDF":
+				convertChapter = func(chapterPath, chapter string) error {
+					pdfOpt := service.PdfOption{
+						Pipeline:  pipeline,
+						Cover:     coverPage(chapterCover(comicPath, chapter, meta.Title, strategy, skip[chapter])),
+						SkipPages: skip[chapter],
+					}
+					return service.ImagesToPDF(chapterPath, comicPath, chapter, pdfOpt)
+				}
 			case "EPUB":
-				wg.Add(len(files))
-				for i := range files {
-					go func(i int) {
-						if !validFolderChapter(files[i]) {
-							wg.Done()
-							return
-						}
-						chapterPath := fmt.Sprintf("out/%s/%d/%s", getWebsiteName(domain), comicId, files[i].Name())
-						cover := env.Cover
-						if cover == "" {
-							cover = randomCover()
-						}
-						epubOpt := epub.EpubOption{
-							Title:  fmt.Sprintf("%s - %s", env.Title, files[i].Name()),
-							Author: env.Author,
-							Cover:  cover,
+				layout, err := epub.ParseLayout(env.EpubLayout)
+				if err != nil {
+					log.Errorf("Invalid %s option: %v", format, err)
+					failed = append(failed, fmt.Errorf("%s: %w", format, err))
+					continue
+				}
+				convertChapter = func(chapterPath, chapter string) error {
+					coverImg := ""
+					if c := chapterCover(comicPath, chapter, meta.Title, strategy, skip[chapter]); len(c.Data) > 0 {
+						coverImg = c.DataURL()
+					}
+					epubOpt := epub.EpubOption{
+						Title:       fmt.Sprintf("%s - %s", meta.Title, names.Chapter(chapter)),
+						Author:      meta.Author,
+						Cover:       coverImg,
+						RTL:         env.RTL,
+						Layout:      layout,
+						PanelView:   env.PanelView,
+						Pipeline:    pipeline,
+						SkipPages:   skip[chapter],
+						TemplateDir: env.TemplateDir,
+						Metadata:    epubMetadata(domain, comicId, chapter, meta),
+					}
+					return epub.ImagesToEPUB(chapterPath, comicPath, chapter, epubOpt)
+				}
+			case "CBZ":
+				convertChapter = func(chapterPath, chapter string) error {
+					cbzOpt := service.CbzOption{
+						Pipeline:  pipeline,
+						Cover:     coverPage(chapterCover(comicPath, chapter, meta.Title, strategy, skip[chapter])),
+						SkipPages: skip[chapter],
+						ComicInfo: comicInfo(domain, chapter, meta),
+					}
+					return service.ImagesToCBZ(chapterPath, comicPath, chapter, cbzOpt)
+				}
+			case "HTML":
+				mode, err := web.ParseMode(env.HtmlMode)
+				if err != nil {
+					log.Errorf("Invalid %s option: %v", format, err)
+					failed = append(failed, fmt.Errorf("%s: %w", format, err))
+					continue
+				}
+				convertChapter = func(chapterPath, chapter string) error {
+					prev, next := neighbours(chapters, chapter)
+					htmlOpt := web.ChapterOption{
+						Title:     meta.Title,
+						Language:  env.Language,
+						RTL:       env.RTL,
+						Mode:      mode,
+						Prev:      prev,
+						Next:      next,
+						Pipeline:  pipeline,
+						SkipPages: skip[chapter],
+					}
+					return web.ChapterToHTML(chapterPath, comicPath, chapter, htmlOpt)
+				}
+			default:
+				log.Errorf("Format not supported: %s", format)
+				failed = append(failed, fmt.Errorf("format not supported: %s", format))
+				continue
+			}
+
+			// Skip outputs built from the same pages and options
+			formatPath := fmt.Sprintf("%s/%s", comicPath, strings.ToLower(format))
+			manifest, err := batch.LoadManifest(formatPath)
+			if err != nil {
+				log.Errorf("Failed to load %s fingerprints: %v", format, err)
+				failed = append(failed, fmt.Errorf("%s: %w", format, err))
+				continue
+			}
+			options := convertOptions(format, cfg, meta)
+			jobs := make([]batch.Job, 0, len(chapters))
+			for _, chapter := range chapters {
+				chapterPath := fmt.Sprintf("%s/%s", comicPath, chapter)
+				output := fmt.Sprintf("%s/%s.%s", formatPath, chapter, strings.ToLower(format))
+				chapterOptions := options
+				if format == "HTML" {
+					// Pages link to the neighbouring chapters
+					prev, next := neighbours(chapters, chapter)
+					output = fmt.Sprintf("%s/%s/index.html", formatPath, chapter)
+					chapterOptions = append(slices.Clone(options), fmt.Sprintf("neighbours %q %q", prev, next))
+				}
+				fingerprint, err := chapterFingerprint(comicPath, chapter, skip[chapter], chapterOptions)
+				if err != nil {
+					log.Errorf("Failed to fingerprint %s: %v", chapter, err)
+					continue
+				}
+				if !force && manifest.Current(output, fingerprint) {
+					log.Infof("%s is up to date, skipping...", output)
+					continue
+				}
+				if dryRun {
+					log.Infof("Would rebuild %s", output)
+					continue
+				}
+				jobs = append(jobs, batch.Job{
+					Chapter: chapter,
+					Run: func() error {
+						if err := ctx.Err(); err != nil {
+							return err
 						}
-						if err := epub.ImagesToEPUB(chapterPath, comicPath, files[i].Name(), epubOpt); err != nil {
-							log.Errorf("Failed to convert %s: %v", files[i].Name(), err)
-							wg.Done()
-							return
+						if err := convertChapter(chapterPath, chapter); err != nil {
+							return err
 						}
-						log.Infof("Converted %s to EPUB", files[i].Name())
-						wg.Done()
-					}(i)
+						return manifest.Set(output, fingerprint)
+					},
+				})
+			}
+
+			err = batch.Run(jobs, env.ConvertWorker, func(done, total int, r batch.Result) {
+				if r.Err != nil {
+					log.Errorf("[%d/%d] Failed to convert %s: %v", done, total, r.Chapter, r.Err)
+					return
 				}
-				wg.Wait()
-			default:
+				log.Infof("[%d/%d] Converted %s to %s", done, total, r.Chapter, format)
+			})
+			if err != nil {
+				log.Errorf("Failed to convert to %s, %v", format, err)
+				failed = append(failed, fmt.Errorf("%s: %w", format, err))
+			}
+			if format == "HTML" && !dryRun {
+				if err := writeHtmlIndex(comicPath, chapters, meta); err != nil {
+					log.Errorf("Failed to write HTML index: %v", err)
+				} else {
+					log.Infof("Open %s/html/index.html to read in a browser", comicPath)
+				}
+			}
+			if report := pipeline.Report(); report.Pages > 0 {
+				log.Infof("%s images: %s", format, report)
 			}
 		}
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return errors.Join(failed...)
+}
+
+// pipelineConfig builds the image pipeline settings of an output format.
+func pipelineConfig(format string) (imaging.Config, error) {
+	device, err := deviceOption()
+	if err != nil {
+		return imaging.Config{}, err
+	}
+	spread, err := imaging.ParseSpreadPolicy(env.SpreadPolicy)
+	if err != nil {
+		return imaging.Config{}, err
+	}
+	encoding, err := imaging.ParseEncoding(formatEncoding(format))
+	if err != nil {
+		return imaging.Config{}, err
+	}
+
+	cfg := imaging.Config{
+		Crop: imaging.CropOption{
+			Auto:            env.AutoCrop,
+			Tolerance:       env.CropTolerance,
+			Limit:           env.CropLimit,
+			WatermarkTop:    env.WatermarkTop,
+			WatermarkBottom: env.WatermarkBottom,
+		},
+		Webtoon: env.Webtoon,
+		Split: imaging.SplitOption{
+			Ratio:       env.WebtoonRatio,
+			MinFragment: env.WebtoonMinFragment,
+			Stitch:      env.WebtoonStitch,
+		},
+		Spread:   spread,
+		RTL:      env.RTL,
+		Device:   device,
+		Encoding: encoding,
+		Quality:  device.Quality,
+		Workers:  env.PipelineWorker,
+	}
+	// WebP pages are lossless, QUALITY would silently do nothing
+	if encoding == imaging.EncodeWebP && env.Quality != env.DEFAULT_QUALITY {
+		return imaging.Config{}, fmt.Errorf("%s pages are lossless, QUALITY only applies to jpeg pages", encoding)
+	}
+	switch format {
+	case "PDF":
+		// PDF keeps the source resolution and colors
+		cfg.Device = imaging.DeviceOption{}
+		if encoding == imaging.EncodeWebP {
+			return imaging.Config{}, fmt.Errorf("PDF does not support %s pages", encoding)
+		}
+	case "HTML":
+		// Browsers keep the source resolution and colors, and show WebP
+		cfg.Device = imaging.DeviceOption{}
+	case "EPUB":
+		// Kindle conversion drops WebP images
+		if encoding == imaging.EncodeWebP && strings.EqualFold(strings.TrimSpace(env.EpubLayout), string(epub.LayoutKindle)) {
+			return imaging.Config{}, fmt.Errorf("kindle layout does not support %s pages", encoding)
+		}
+	}
+	return cfg, nil
+}
+
+// convertOptions returns the settings a converted chapter depends on, so
+// changing any of them rebuilds it.
+func convertOptions(format string, cfg imaging.Config, meta series) []string {
+	cfg.Workers = 0
+	options := []string{
+		format,
+		fmt.Sprintf("%+v", cfg),
+		fmt.Sprintf("cover %q %q %q %q", env.CoverStrategy, env.Cover, env.CoverFont, meta.Title),
+	}
+	switch format {
+	case "EPUB":
+		options = append(options,
+			fmt.Sprintf("epub %q %q %q %v %v %q", env.EpubLayout, meta.Title, meta.Author, env.RTL, env.PanelView, env.TemplateDir),
+			fmt.Sprintf("metadata %q %q %q %q", env.Language, env.Publisher, meta.Description, meta.Subjects),
+		)
+	case "CBZ":
+		options = append(options,
+			fmt.Sprintf("comicinfo %q %q %v %q %q %q %q", meta.Title, meta.Author, env.RTL, env.Language, env.Publisher, meta.Description, meta.Subjects),
+		)
+	}
+	return options
+}
+
+// chapterFingerprint hashes the pages of a chapter left after ad detection,
+// the covers it may use and the conversion options.
+func chapterFingerprint(comicPath, chapter string, skip map[string]bool, options []string) (string, error) {
+	pages, err := imaging.SourcePages(fmt.Sprintf("%s/%s", comicPath, chapter), skip)
+	if err != nil {
+		return "", err
+	}
+	files := append(pages, fmt.Sprintf("%s/%s", comicPath, cover.SeriesFile))
+	if env.Cover != "" {
+		files = append(files, env.Cover)
+	}
+	return batch.Fingerprint(files, options...)
+}
 
+// convertFlags parses the flags of convert, which also runs when no command
+// is given.
+func convertFlags() (force, dryRun bool, err error) {
+	arguments := args()
+	if strings.HasPrefix(command(), "-") {
+		arguments = os.Args[1:]
+	}
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	fs.BoolVar(&force, "force", false, "rebuild outputs which are up to date")
+	fs.BoolVar(&dryRun, "dry-run", false, "list the outputs to rebuild without converting")
+	err = fs.Parse(arguments)
+	return force, dryRun, err
+}
+
+// formatEncoding returns the configured page encoding of an output format.
+func formatEncoding(format string) string {
+	switch format {
+	case "PDF":
+		return env.PdfEncoding
+	case "CBZ":
+		return env.CbzEncoding
+	case "HTML":
+		return env.HtmlEncoding
+	default:
+		return env.EpubEncoding
+	}
+}
+
+// chapterCover resolves the cover of a chapter. Failures are only logged,
+// the chapter is converted without a cover.
+func chapterCover(comicPath, chapter, title string, strategy cover.Strategy, skip map[string]bool) cover.Cover {
+	c, err := cover.Resolve(fmt.Sprintf("%s/%s", comicPath, chapter), cover.Option{
+		Strategy:  strategy,
+		Path:      env.Cover,
+		Series:    fmt.Sprintf("%s/%s", comicPath, cover.SeriesFile),
+		Title:     title,
+		Chapter:   chapter,
+		Font:      env.CoverFont,
+		SkipPages: skip,
+	})
+	if err != nil {
+		log.Warnf("Failed to load cover of %s: %v", chapter, err)
+	}
+	return c
+}
+
+// coverPage returns the cover image shown as first page of formats without
+// cover metadata, when it is not already a page of the chapter.
+func coverPage(c cover.Cover) []byte {
+	if !c.Page {
+		return nil
+	}
+	return c.Data
+}
+
+// neighbours returns the chapters before and after chapter in reading order.
+func neighbours(chapters []string, chapter string) (prev, next string) {
+	i := slices.Index(chapters, chapter)
+	if i > 0 {
+		prev = chapters[i-1]
+	}
+	if i >= 0 && i < len(chapters)-1 {
+		next = chapters[i+1]
+	}
+	return prev, next
+}
+
+// writeHtmlIndex writes the series page of the HTML export.
+func writeHtmlIndex(comicPath string, chapters []string, meta series) error {
+	art, err := os.ReadFile(fmt.Sprintf("%s/%s", comicPath, cover.SeriesFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return web.WriteIndex(comicPath, web.IndexOption{
+		Title:    meta.Title,
+		Author:   meta.Author,
+		Language: env.Language,
+		Cover:    art,
+		Chapters: chapters,
+	})
+}
+
+// epubMetadata describes a chapter as part of the comic series, numbered by
+// the chapter number of its folder name.
+func epubMetadata(domain string, comicId int, chapter string, meta series) epub.Metadata {
+	number, _, _, _, _ := crawler.ParseChapterName(chapter)
+	return epub.Metadata{
+		Identifier:  epub.Identifier(getWebsiteName(domain), strconv.Itoa(comicId), chapter),
+		Series:      meta.Title,
+		SeriesIndex: number,
+		Language:    env.Language,
+		Publisher:   publisher(domain),
+		Description: meta.Description,
+		Subjects:    meta.subjects(),
+	}
+}
+
+// comicInfo describes a chapter for media servers reading CBZ archives.
+func comicInfo(domain, chapter string, meta series) *service.ComicInfo {
+	number, hasNumber, volume, title, _ := crawler.ParseChapterName(chapter)
+	info := &service.ComicInfo{
+		Title:       title,
+		Series:      meta.Title,
+		Volume:      volume,
+		Summary:     meta.Description,
+		Writer:      meta.Author,
+		Publisher:   publisher(domain),
+		Genre:       strings.Join(meta.subjects(), ", "),
+		LanguageISO: env.Language,
+		Manga:       "No",
+	}
+	if hasNumber {
+		info.Number = service.FormatNumber(number)
+	}
+	if env.RTL {
+		info.Manga = "YesAndRightToLeft"
+	}
+	return info
+}
+
+// publisher returns the configured publisher, the source site by default.
+func publisher(domain string) string {
+	if env.Publisher != "" {
+		return env.Publisher
+	}
+	return getWebsiteName(domain)
+}
+
+// series is the metadata written to the converted chapters of a comic.
+type series struct {
+	Title       string
+	Author      string
+	Description string
+	// Comma separated genres
+	Subjects string
+}
+
+// envSeries returns the series metadata configured in the environment.
+func envSeries() series {
+	return series{
+		Title:       env.Title,
+		Author:      env.Author,
+		Description: env.Description,
+		Subjects:    env.Subjects,
+	}
+}
+
+// subjects returns the genres of the series.
+func (s series) subjects() []string {
+	res := make([]string, 0)
+	for _, subject := range strings.Split(s.Subjects, ",") {
+		if subject = strings.TrimSpace(subject); subject != "" {
+			res = append(res, subject)
+		}
+	}
+	return res
+}
+
+func adOption() dedupe.Option {
+	return dedupe.Option{
+		MinChapters: env.AdMinChapters,
+		Distance:    env.AdHashDistance,
+		Blocklist:   env.AdBlocklist,
+	}
+}
+
+func deviceOption() (imaging.DeviceOption, error) {
+	opt := imaging.DeviceOption{
+		Gamma:    env.Gamma,
+		Contrast: env.Contrast,
+		Quality:  env.Quality,
+	}
+	if env.DeviceProfile != "" {
+		profile, err := imaging.ParseProfile(env.DeviceProfile)
+		if err != nil {
+			return opt, err
+		}
+		opt.Profile = profile
+	}
+	grayscale, err := imaging.ParseGrayscale(env.Grayscale, opt.Profile)
+	if err != nil {
+		return opt, err
+	}
+	opt.Grayscale = grayscale
+	if opt.Quality <= 0 || opt.Quality > 100 {
+		opt.Quality = env.DEFAULT_QUALITY
+	}
+	return opt, nil
 }
 
 type URL struct {
@@ -248,38 +1024,6 @@ func getFolderPath(domain, chapterName string, comicId int) string {
 	return fmt.Sprintf("out/%s/%d/%s/", getWebsiteName(domain), comicId, chapterName)
 }
 
-func validFolderChapter(f os.DirEntry) bool {
-	isFile := !f.IsDir()
-	if isFile ||
-		f.Name() == "epub" ||
-		f.Name() == "pdf" ||
-		f.Name() == "temp" {
-		return false
-	}
-	return true
-}
-
-func randomCover() string {
-	folderPath := "assets/default"
-	files, err := os.ReadDir(folderPath)
-	if err != nil {
-		log.Errorf("Failed to read default cover folder: %v", err)
-		return ""
-	}
-	imgs := make([]string, 0)
-	for _, file := range files {
-		if !file.IsDir() &&
-			strings.Contains(file.Name(), ".jpg") &&
-			strings.Contains(file.Name(), "default_cover_") {
-			imgs = append(imgs, fmt.Sprintf("%s/%s", folderPath, file.Name()))
-		}
-	}
-
-	s := rand.NewSource(time.Now().Unix())
-	r := rand.New(s)
-	return imgs[r.Intn(len(imgs))]
-}
-
 func getWebsiteName(domain string) string {
 	var websiteName = map[string]string{
 		env.NettruyenDomain: "nettruyen",
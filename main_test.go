@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"comic-crawler/env"
+)
+
+// writePage writes a page of a single shade.
+func writePage(t *testing.T, path string, shade uint8) {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 60, 90))
+	for i := range img.Pix {
+		img.Pix[i] = shade
+	}
+	img.SetGray(0, 0, color.Gray{Y: 255 - shade})
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := jpeg.Encode(f, img, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConvertIncremental(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	data := "COMIC_ID=1\nCOVER_STRATEGY=first-page\nAD_DETECT=FALSE\n"
+	if err := os.WriteFile(".env", []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Init(); err != nil {
+		t.Fatal(err)
+	}
+	domain := env.NettruyenDomain
+	comicPath := filepath.Join("out", getWebsiteName(domain), "1")
+	for i, chapter := range []string{"Chapter 1", "Chapter 2"} {
+		writePage(t, filepath.Join(comicPath, chapter, "1.jpg"), uint8(40*i))
+		writePage(t, filepath.Join(comicPath, chapter, "2.jpg"), 200)
+	}
+	outputs := []string{
+		"epub/Chapter 1.epub",
+		"epub/Chapter 2.epub",
+		"html/Chapter 1/index.html",
+		"html/Chapter 2/index.html",
+	}
+
+	// run converts and returns the outputs skipped as up to date
+	run := func(force, dryRun bool) []string {
+		t.Helper()
+		var job bytes.Buffer
+		err := convert(context.Background(), convertOption{
+			Domain:  domain,
+			ComicId: 1,
+			Formats: "EPUB,HTML",
+			Series:  envSeries(),
+			Force:   force,
+			DryRun:  dryRun,
+			Log:     logger{job: &job},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		skipped := make([]string, 0)
+		for _, output := range outputs {
+			if strings.Contains(job.String(), filepath.ToSlash(filepath.Join(comicPath, output))+" is up to date") {
+				skipped = append(skipped, output)
+			}
+		}
+		sort.Strings(skipped)
+		return skipped
+	}
+	// touched ages the outputs and returns those rewritten by f
+	touched := func(f func()) []string {
+		t.Helper()
+		old := time.Now().Add(-time.Hour).Truncate(time.Second)
+		for _, output := range outputs {
+			if err := os.Chtimes(filepath.Join(comicPath, output), old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+		f()
+		rebuilt := make([]string, 0)
+		for _, output := range outputs {
+			info, err := os.Stat(filepath.Join(comicPath, output))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !info.ModTime().Equal(old) {
+				rebuilt = append(rebuilt, output)
+			}
+		}
+		return rebuilt
+	}
+
+	if skipped := run(false, false); len(skipped) != 0 {
+		t.Fatalf("first run skipped %v", skipped)
+	}
+
+	tests := []struct {
+		name        string
+		change      func()
+		force       bool
+		dryRun      bool
+		incremental bool
+		want        []string // rebuilt outputs
+	}{
+		{
+			name:        "unchanged",
+			incremental: true,
+			want:        []string{},
+		},
+		{
+			name:        "changed page",
+			change:      func() { writePage(t, filepath.Join(comicPath, "Chapter 2", "1.jpg"), 120) },
+			incremental: true,
+			want:        []string{"epub/Chapter 2.epub", "html/Chapter 2/index.html"},
+		},
+		{
+			name:        "added page",
+			change:      func() { writePage(t, filepath.Join(comicPath, "Chapter 1", "3.jpg"), 80) },
+			dryRun:      true,
+			incremental: true,
+			want:        []string{},
+		},
+		{
+			// The page added in dry run is still pending
+			name:        "after dry run",
+			incremental: true,
+			want:        []string{"epub/Chapter 1.epub", "html/Chapter 1/index.html"},
+		},
+		{
+			name:        "force",
+			force:       true,
+			incremental: true,
+			want:        outputs,
+		},
+		{
+			name: "CONVERT_INCREMENTAL=FALSE",
+			want: outputs,
+		},
+	}
+	for _, tt := range tests {
+		env.ConvertIncremental = tt.incremental
+		if tt.change != nil {
+			tt.change()
+		}
+		var skipped []string
+		rebuilt := touched(func() { skipped = run(tt.force, tt.dryRun) })
+		if !reflect.DeepEqual(rebuilt, tt.want) {
+			t.Errorf("%s: rebuilt %v, want %v", tt.name, rebuilt, tt.want)
+		}
+		if tt.dryRun {
+			continue
+		}
+		if len(skipped)+len(rebuilt) != len(outputs) {
+			t.Errorf("%s: skipped %v and rebuilt %v, want every other output skipped", tt.name, skipped, rebuilt)
+		}
+	}
+}
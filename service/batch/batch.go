@@ -0,0 +1,77 @@
+package batch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Job converts a chapter to one output format.
+type Job struct {
+	Chapter string
+	Run     func() error
+}
+
+// Result is the outcome of a job, reported as jobs finish.
+type Result struct {
+	Chapter string
+	Err     error
+}
+
+// Errors lists the chapters which failed to convert.
+type Errors []Result
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, r := range e {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", r.Chapter, r.Err))
+	}
+	return fmt.Sprintf("%d chapters failed to convert:\n%s", len(e), strings.Join(msgs, "\n"))
+}
+
+// Run converts chapters with at most workers jobs at once, so only that many
+// chapters are held in memory. progress is called after each job with the
+// number of jobs done. The failed chapters are returned as Errors.
+func Run(jobs []Job, workers int, progress func(done, total int, r Result)) error {
+	workers = max(1, min(workers, len(jobs)))
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		done   int
+		failed Errors
+	)
+	queue := make(chan Job)
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				r := Result{Chapter: job.Chapter, Err: job.Run()}
+				mu.Lock()
+				done++
+				if r.Err != nil {
+					failed = append(failed, r)
+				}
+				if progress != nil {
+					progress(done, len(jobs), r)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	if len(failed) == 0 {
+		return nil
+	}
+	sort.Slice(failed, func(i, j int) bool {
+		return failed[i].Chapter < failed[j].Chapter
+	})
+	return failed
+}
@@ -0,0 +1,52 @@
+package batch
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	var running, peak atomic.Int32
+	jobs := make([]Job, 0)
+	for _, chapter := range []string{"ch1", "ch2", "ch3", "ch4", "ch5"} {
+		jobs = append(jobs, Job{Chapter: chapter, Run: func() error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			if chapter == "ch2" || chapter == "ch4" {
+				return errors.New("broken")
+			}
+			return nil
+		}})
+	}
+
+	last := 0
+	err := Run(jobs, 2, func(done, total int, r Result) {
+		if done != last+1 || total != len(jobs) {
+			t.Errorf("progress %d/%d after %d", done, total, last)
+		}
+		last = done
+	})
+	if peak.Load() > 2 {
+		t.Errorf("%d jobs ran at once, want at most 2", peak.Load())
+	}
+	if last != len(jobs) {
+		t.Errorf("progress reported %d jobs, want %d", last, len(jobs))
+	}
+	var failed Errors
+	if !errors.As(err, &failed) || len(failed) != 2 || failed[0].Chapter != "ch2" || failed[1].Chapter != "ch4" {
+		t.Errorf("got %v, want ch2 and ch4 failures", err)
+	}
+
+	if err := Run(nil, 4, nil); err != nil {
+		t.Errorf("no jobs: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package batch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FingerprintFile records the fingerprints of the outputs in a format folder.
+const FingerprintFile = "fingerprints.json"
+
+// Fingerprint hashes the options used to convert a chapter and its input
+// files by name, size and content. Missing files, such as an optional
+// series cover, are part of the fingerprint.
+func Fingerprint(files []string, options ...string) (string, error) {
+	h := sha256.New()
+	for _, option := range options {
+		fmt.Fprintf(h, "option %q\n", option)
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if os.IsNotExist(err) {
+			fmt.Fprintf(h, "missing %q\n", filepath.Base(f))
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "file %q %d %x\n", filepath.Base(f), len(data), sha256.Sum256(data))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Manifest maps the outputs of a folder, by path relative to the folder, to
+// the fingerprint of the inputs they were built from.
+type Manifest struct {
+	dir     string
+	path    string
+	mu      sync.Mutex
+	outputs map[string]string
+}
+
+// LoadManifest reads the fingerprints of the outputs in dir, none if the
+// folder was never converted.
+func LoadManifest(dir string) (*Manifest, error) {
+	m := &Manifest{dir: dir, path: filepath.Join(dir, FingerprintFile), outputs: make(map[string]string)}
+	data, err := os.ReadFile(m.path)
+	if os.IsNotExist(err) {
+		return m, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &m.outputs); err != nil {
+		return nil, fmt.Errorf("%s: %w", m.path, err)
+	}
+	return m, nil
+}
+
+// Current reports whether output exists and was built from fingerprint.
+func (m *Manifest) Current(output, fingerprint string) bool {
+	m.mu.Lock()
+	recorded := m.outputs[m.key(output)]
+	m.mu.Unlock()
+	if recorded != fingerprint {
+		return false
+	}
+	_, err := os.Stat(output)
+	return err == nil
+}
+
+// Set records the fingerprint of a built output and saves the manifest, so
+// interrupted runs keep the outputs already built.
+func (m *Manifest) Set(output, fingerprint string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outputs[m.key(output)] = fingerprint
+	data, err := json.MarshalIndent(m.outputs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(m.path, data, 0644)
+}
+
+// key names an output relative to the folder, as outputs such as HTML
+// chapters share their file name: <chapter>/index.html.
+func (m *Manifest) key(output string) string {
+	rel, err := filepath.Rel(m.dir, output)
+	if err != nil {
+		return filepath.Base(output)
+	}
+	return filepath.ToSlash(rel)
+}
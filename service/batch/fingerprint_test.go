@@ -0,0 +1,99 @@
+package batch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	page := filepath.Join(dir, "1.jpg")
+	missing := filepath.Join(dir, "cover.jpg")
+	if err := os.WriteFile(page, []byte("page"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fingerprint := func(options ...string) string {
+		t.Helper()
+		fp, err := Fingerprint([]string{page, missing}, options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fp
+	}
+	base := fingerprint("EPUB", "quality 90")
+	if fingerprint("EPUB", "quality 90") != base {
+		t.Error("fingerprint is not stable")
+	}
+	if fingerprint("EPUB", "quality 80") == base {
+		t.Error("options do not change the fingerprint")
+	}
+	if err := os.WriteFile(page, []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if fingerprint("EPUB", "quality 90") == base {
+		t.Error("page content does not change the fingerprint")
+	}
+}
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "ch1.epub")
+
+	m, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Current(output, "abc") {
+		t.Error("unrecorded output is current")
+	}
+	if err := m.Set(output, "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if m.Current(output, "abc") {
+		t.Error("missing output is current")
+	}
+	if err := os.WriteFile(output, []byte("epub"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err = LoadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Current(output, "abc") {
+		t.Error("saved fingerprint is not current")
+	}
+	if m.Current(output, "def") {
+		t.Error("changed fingerprint is current")
+	}
+
+	// HTML chapters are all named index.html
+	a := filepath.Join(dir, "Chapter 1", "index.html")
+	b := filepath.Join(dir, "Chapter 2", "index.html")
+	for _, output := range []string{a, b} {
+		if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(output, []byte("html"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := m.Set(a, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Set(b, "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err = LoadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Chapters sharing the file name keep their own fingerprint
+	if !m.Current(a, "a") || !m.Current(b, "b") {
+		t.Errorf("current: a %v, b %v, want both", m.Current(a, "a"), m.Current(b, "b"))
+	}
+}
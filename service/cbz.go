@@ -0,0 +1,81 @@
+package service
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+
+	"comic-crawler/service/imaging"
+
+	"github.com/vukyn/kuery/file"
+)
+
+type CbzOption struct {
+	Pipeline *imaging.Pipeline
+	// Cover stored as the first image, if any
+	Cover []byte
+	// Pages detected as ads or duplicates, by file name
+	SkipPages map[string]bool
+	// Metadata written as ComicInfo.xml, if any
+	ComicInfo *ComicInfo
+}
+
+func ImagesToCBZ(folderPath string, filePath, fileName string, opt CbzOption) error {
+	// Process pages in memory
+	sources, err := imaging.SourcePages(folderPath, opt.SkipPages)
+	if err != nil {
+		return err
+	}
+	pages, err := opt.Pipeline.Run(sources)
+	if err != nil {
+		return err
+	}
+
+	if err := file.CreateFilePath(fmt.Sprintf("%s/cbz/", filePath)); err != nil {
+		return err
+	}
+	output := fmt.Sprintf("%s/cbz/%s.cbz", filePath, fileName)
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Pages are already compressed, store them as is
+	zw := zip.NewWriter(f)
+	// Pages are numbered from 001, the cover is 000
+	first := 1
+	if len(opt.Cover) > 0 {
+		pages = append([]imaging.Output{{Data: opt.Cover}}, pages...)
+		first = 0
+	}
+	if opt.ComicInfo != nil {
+		info := *opt.ComicInfo
+		info.PageCount = len(pages)
+		data, err := info.marshal()
+		if err != nil {
+			return err
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: ComicInfoFile, Method: zip.Deflate})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	for i, page := range pages {
+		name := fmt.Sprintf("%03d%s", first+i, imaging.Extension(page.MediaType()))
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(page.Data); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"comic-crawler/service/imaging"
+)
+
+func writeJPEG(t *testing.T, path string, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// readCBZ returns the entries of a CBZ archive in order.
+func readCBZ(t *testing.T, path string) []*zip.File {
+	t.Helper()
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r.File
+}
+
+func readEntry(t *testing.T, f *zip.File) []byte {
+	t.Helper()
+	rc, err := f.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestImagesToCBZ(t *testing.T) {
+	chapter := t.TempDir()
+	sources := map[string][]byte{}
+	for i, name := range []string{"1.jpg", "2.jpg", "10.jpg", "ad.jpg"} {
+		sources[name] = writeJPEG(t, filepath.Join(chapter, name), 20+i, 30)
+	}
+	var cover bytes.Buffer
+	if err := png.Encode(&cover, image.NewRGBA(image.Rect(0, 0, 10, 10))); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		encoding  imaging.Encoding
+		opt       CbzOption
+		want      []string
+		wantPages [][]byte // page data, nil to only check the media type
+		mediaType string
+	}{
+		{
+			name:      "original",
+			encoding:  imaging.EncodeOriginal,
+			want:      []string{"001.jpg", "002.jpg", "003.jpg"},
+			wantPages: [][]byte{sources["1.jpg"], sources["2.jpg"], sources["10.jpg"]},
+			mediaType: "image/jpeg",
+		},
+		{
+			name:      "cover and metadata",
+			encoding:  imaging.EncodeOriginal,
+			opt:       CbzOption{Cover: cover.Bytes(), SkipPages: map[string]bool{"2.jpg": true}, ComicInfo: &ComicInfo{Series: "Series", Number: "1"}},
+			want:      []string{ComicInfoFile, "000.png", "001.jpg", "002.jpg"},
+			wantPages: [][]byte{cover.Bytes(), sources["1.jpg"], sources["10.jpg"]},
+		},
+		{
+			name:      "webp",
+			encoding:  imaging.EncodeWebP,
+			want:      []string{"001.webp", "002.webp", "003.webp"},
+			mediaType: "image/webp",
+		},
+	}
+	for _, tt := range tests {
+		out := t.TempDir()
+		tt.opt.Pipeline = imaging.NewPipeline(imaging.Config{Encoding: tt.encoding, Quality: 90, Workers: 2})
+		if err := ImagesToCBZ(chapter, out, "Chapter 1", tt.opt); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		files := readCBZ(t, filepath.Join(out, "cbz", "Chapter 1.cbz"))
+		names := make([]string, len(files))
+		for i, f := range files {
+			names[i] = f.Name
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("%s: entries = %v, want %v", tt.name, names, tt.want)
+		}
+
+		pages := files
+		if tt.opt.ComicInfo != nil {
+			pages = files[1:]
+			var info ComicInfo
+			if err := xml.Unmarshal(readEntry(t, files[0]), &info); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if info.Series != "Series" || info.PageCount != len(pages) {
+				t.Errorf("%s: ComicInfo = %+v, want series Series and %d pages", tt.name, info, len(pages))
+			}
+			if files[0].Method != zip.Deflate {
+				t.Errorf("%s: %s method = %d, want deflate", tt.name, ComicInfoFile, files[0].Method)
+			}
+		}
+		for i, f := range pages {
+			if f.Method != zip.Store {
+				t.Errorf("%s: %s method = %d, want store", tt.name, f.Name, f.Method)
+			}
+			data := readEntry(t, f)
+			if tt.wantPages != nil && i < len(tt.wantPages) && !bytes.Equal(data, tt.wantPages[i]) {
+				t.Errorf("%s: %s is not the source image", tt.name, f.Name)
+			}
+			if tt.mediaType != "" && http.DetectContentType(data) != tt.mediaType {
+				t.Errorf("%s: %s media type = %s, want %s", tt.name, f.Name, http.DetectContentType(data), tt.mediaType)
+			}
+		}
+	}
+}
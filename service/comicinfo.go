@@ -0,0 +1,48 @@
+package service
+
+import (
+	"encoding/xml"
+	"strconv"
+)
+
+// ComicInfoFile is the metadata file media servers such as Komga and Kavita
+// read from CBZ archives.
+const ComicInfoFile = "ComicInfo.xml"
+
+// ComicInfo is the ComicRack metadata of a chapter, schema v2.0.
+type ComicInfo struct {
+	XMLName     xml.Name `xml:"ComicInfo"`
+	XmlnsXsi    string   `xml:"xmlns:xsi,attr"`
+	XmlnsXsd    string   `xml:"xmlns:xsd,attr"`
+	Title       string   `xml:"Title,omitempty"`
+	Series      string   `xml:"Series,omitempty"`
+	Number      string   `xml:"Number,omitempty"`
+	Volume      int      `xml:"Volume,omitempty"`
+	Summary     string   `xml:"Summary,omitempty"`
+	Year        int      `xml:"Year,omitempty"`
+	Month       int      `xml:"Month,omitempty"`
+	Day         int      `xml:"Day,omitempty"`
+	Writer      string   `xml:"Writer,omitempty"`
+	Publisher   string   `xml:"Publisher,omitempty"`
+	Genre       string   `xml:"Genre,omitempty"`
+	Web         string   `xml:"Web,omitempty"`
+	PageCount   int      `xml:"PageCount,omitempty"`
+	LanguageISO string   `xml:"LanguageISO,omitempty"`
+	// YesAndRightToLeft for manga read right to left, No otherwise
+	Manga string `xml:"Manga,omitempty"`
+}
+
+// FormatNumber writes a chapter number without trailing zeros: 12, 12.5.
+func FormatNumber(number float64) string {
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
+
+func (c ComicInfo) marshal() ([]byte, error) {
+	c.XmlnsXsi = "http://www.w3.org/2001/XMLSchema-instance"
+	c.XmlnsXsd = "http://www.w3.org/2001/XMLSchema"
+	data, err := xml.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
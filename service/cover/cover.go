@@ -0,0 +1,269 @@
+package cover
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"net/http"
+	"os"
+	"strings"
+	"unicode"
+
+	"comic-crawler/assets"
+	"comic-crawler/service/imaging"
+
+	"github.com/anthonynsimon/bild/transform"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/text/unicode/norm"
+)
+
+// SeriesFile is the series cover downloaded next to the chapter folders.
+const SeriesFile = "cover.jpg"
+
+// Strategy selects where chapter covers come from.
+type Strategy string
+
+const (
+	Random    Strategy = "random"     // stock cover
+	Series    Strategy = "series"     // scraped series cover
+	FirstPage Strategy = "first-page" // first page of the chapter
+	Generated Strategy = "generated"  // series art with title and chapter text
+)
+
+func ParseStrategy(strategy string) (Strategy, error) {
+	switch s := Strategy(strings.ToLower(strings.TrimSpace(strategy))); s {
+	case "":
+		return Random, nil
+	case Random, Series, FirstPage, Generated:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown cover strategy %q, use one of random, series, first-page, generated", strategy)
+	}
+}
+
+type Option struct {
+	Strategy Strategy
+	Path     string // cover file used for every chapter, overrides the strategy
+	Series   string // series cover file
+	Title    string
+	Chapter  string
+	Font     string // TrueType or OpenType font of generated covers, Go Bold if empty
+	// Pages detected as ads or duplicates, by file name
+	SkipPages map[string]bool
+}
+
+// Cover is a chapter cover image.
+type Cover struct {
+	Data []byte
+	// The cover is not a page of the chapter, formats without cover metadata
+	// (PDF, CBZ) show it as their first page
+	Page bool
+}
+
+// Resolve returns the cover of a chapter. Missing series art falls back to
+// the chapter's first page.
+func Resolve(chapterPath string, opt Option) (Cover, error) {
+	if opt.Path != "" {
+		data, err := os.ReadFile(opt.Path)
+		if err != nil {
+			return Cover{}, err
+		}
+		return normalize(data, true)
+	}
+
+	switch opt.Strategy {
+	case Series, Generated:
+		art, err := os.ReadFile(opt.Series)
+		if os.IsNotExist(err) {
+			if art, err = firstPage(chapterPath, opt.SkipPages); err != nil {
+				return Cover{}, err
+			}
+		} else if err != nil {
+			return Cover{}, err
+		}
+		if opt.Strategy == Series {
+			return normalize(art, true)
+		}
+
+		img, _, err := image.Decode(bytes.NewReader(art))
+		if err != nil {
+			return Cover{}, err
+		}
+		var ttf []byte
+		if opt.Font != "" {
+			if ttf, err = os.ReadFile(opt.Font); err != nil {
+				return Cover{}, err
+			}
+		}
+		generated, err := Generate(img, opt.Title, opt.Chapter, ttf)
+		if err != nil {
+			return Cover{}, err
+		}
+		data, err := imaging.Encode(generated, imaging.EncodeJPEG, 90)
+		if err != nil {
+			return Cover{}, err
+		}
+		return Cover{Data: data, Page: true}, nil
+	case FirstPage:
+		data, err := firstPage(chapterPath, opt.SkipPages)
+		if err != nil {
+			return Cover{}, err
+		}
+		return normalize(data, false)
+	default:
+		_, data, err := assets.RandomCover()
+		if err != nil {
+			return Cover{}, err
+		}
+		return Cover{Data: data}, nil
+	}
+}
+
+func firstPage(chapterPath string, skip map[string]bool) ([]byte, error) {
+	pages, err := imaging.SourcePages(chapterPath, skip)
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no pages found in %s", chapterPath)
+	}
+	return os.ReadFile(pages[0])
+}
+
+// normalize re-encodes covers which are not JPEG or PNG, the formats every
+// output supports.
+func normalize(data []byte, page bool) (Cover, error) {
+	switch http.DetectContentType(data) {
+	case "image/jpeg", "image/png":
+		return Cover{Data: data, Page: page}, nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Cover{}, err
+	}
+	if data, err = imaging.Encode(img, imaging.EncodeJPEG, 90); err != nil {
+		return Cover{}, err
+	}
+	return Cover{Data: data, Page: page}, nil
+}
+
+// DataURL returns the cover as an inline data url.
+func (c Cover) DataURL() string {
+	return fmt.Sprintf("data:%s;base64,%s", http.DetectContentType(c.Data), base64.StdEncoding.EncodeToString(c.Data))
+}
+
+// Size of generated covers
+const (
+	coverWidth  = 1200
+	coverHeight = 1800
+)
+
+// Generate composites series art filling a portrait cover with the title and
+// chapter written over a dark band at the bottom, in the given font or Go Bold.
+func Generate(art image.Image, title, chapter string, ttf []byte) (image.Image, error) {
+	if len(ttf) == 0 {
+		ttf = gobold.TTF
+	}
+	bold, err := opentype.Parse(ttf)
+	if err != nil {
+		return nil, err
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, coverWidth, coverHeight))
+
+	// Scale the art to fill the cover and crop the overflow around the center
+	b := art.Bounds()
+	scale := max(float64(coverWidth)/float64(b.Dx()), float64(coverHeight)/float64(b.Dy()))
+	w, h := int(float64(b.Dx())*scale+0.5), int(float64(b.Dy())*scale+0.5)
+	scaled := transform.Resize(art, w, h, transform.Linear)
+	draw.Draw(dst, dst.Bounds(), scaled, image.Pt((w-coverWidth)/2, (h-coverHeight)/2), draw.Src)
+
+	// Darken the bottom of the cover, fading in
+	band := coverHeight * 2 / 5
+	for y := coverHeight - band; y < coverHeight; y++ {
+		alpha := uint8(220 * min(1, float64(y-coverHeight+band)/float64(band/2)))
+		shade := image.NewUniform(color.NRGBA{0, 0, 0, alpha})
+		draw.Draw(dst, image.Rect(0, y, coverWidth, y+1), shade, image.Point{}, draw.Over)
+	}
+
+	// Text is laid out upwards from the bottom margin
+	margin := coverWidth / 12
+	y := coverHeight - margin
+	texts := []struct {
+		text  string
+		size  float64
+		color color.Color
+	}{
+		{chapter, 56, color.NRGBA{220, 220, 220, 255}},
+		{title, 96, color.White},
+	}
+	for _, t := range texts {
+		if strings.TrimSpace(t.text) == "" {
+			continue
+		}
+		face, err := opentype.NewFace(bold, &opentype.FaceOptions{Size: t.size, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			return nil, err
+		}
+		height := face.Metrics().Height.Ceil()
+		lines := wrap(face, supported(face, t.text), coverWidth-2*margin)
+		d := &font.Drawer{Dst: dst, Src: image.NewUniform(t.color), Face: face}
+		for i, line := range lines {
+			d.Dot = fixed.P(margin, y-(len(lines)-1-i)*height)
+			d.DrawString(line)
+		}
+		y -= height*len(lines) + margin/4
+	}
+	return dst, nil
+}
+
+// supported replaces letters missing from the font with the closest letter
+// it has, dropping diacritics one by one: ừ becomes ù when the font lacks
+// the horn.
+func supported(face font.Face, text string) string {
+	has := func(r rune) bool {
+		_, ok := face.GlyphAdvance(r)
+		return ok
+	}
+	var b strings.Builder
+	for _, r := range text {
+		if has(r) || unicode.IsSpace(r) {
+			b.WriteRune(r)
+			continue
+		}
+		runes := []rune(norm.NFD.String(string(r)))
+		letter := runes[0]
+		for _, mark := range runes[1:] {
+			if c := []rune(norm.NFC.String(string([]rune{letter, mark}))); len(c) == 1 && has(c[0]) {
+				letter = c[0]
+			}
+		}
+		if has(letter) {
+			b.WriteRune(letter)
+		}
+	}
+	return b.String()
+}
+
+// wrap breaks text into lines fitting width.
+func wrap(face font.Face, text string, width int) []string {
+	lines := make([]string, 0)
+	line := ""
+	for _, word := range strings.Fields(text) {
+		next := strings.TrimSpace(line + " " + word)
+		if line != "" && font.MeasureString(face, next).Ceil() > width {
+			lines = append(lines, line)
+			next = word
+		}
+		line = next
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,137 @@
+package cover
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+)
+
+func writePage(t *testing.T, path string, c color.Color) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 60, 90))
+	for y := 0; y < 90; y++ {
+		for x := 0; x < 60; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	chapter := filepath.Join(dir, "Chapter 1")
+	if err := os.Mkdir(chapter, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writePage(t, filepath.Join(chapter, "2.jpg"), color.Black)
+	writePage(t, filepath.Join(chapter, "1.jpg"), color.White)
+	first, _ := os.ReadFile(filepath.Join(chapter, "1.jpg"))
+	series := filepath.Join(dir, SeriesFile)
+
+	tests := []struct {
+		name     string
+		opt      Option
+		wantData []byte
+		wantPage bool
+	}{
+		{"first page", Option{Strategy: FirstPage}, first, false},
+		{"missing series falls back to first page", Option{Strategy: Series, Series: series}, first, true},
+		{"random", Option{Strategy: Random}, nil, false},
+	}
+	for _, tt := range tests {
+		c, err := Resolve(chapter, tt.opt)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if tt.wantData != nil && !bytes.Equal(c.Data, tt.wantData) {
+			t.Errorf("%s: unexpected cover", tt.name)
+		}
+		if len(c.Data) == 0 || c.Page != tt.wantPage {
+			t.Errorf("%s: got %d bytes, page %v", tt.name, len(c.Data), c.Page)
+		}
+	}
+
+	writePage(t, series, color.RGBA{200, 0, 0, 255})
+	c, err := Resolve(chapter, Option{Strategy: Generated, Series: series, Title: "Title", Chapter: "Chapter 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(c.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Page || img.Bounds().Dx() != coverWidth || img.Bounds().Dy() != coverHeight {
+		t.Errorf("generated: got %v, page %v", img.Bounds(), c.Page)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	art := image.NewRGBA(image.Rect(0, 0, 300, 200))
+	img, err := Generate(art, "A very long series title that needs more than one line", "Chapter 12", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rgba := img.(*image.RGBA)
+
+	// Text is drawn in the bottom band only
+	lit := func(y0, y1 int) bool {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < coverWidth; x++ {
+				if rgba.RGBAAt(x, y).R > 128 {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if lit(0, coverHeight/2) {
+		t.Error("text drawn over the top of the cover")
+	}
+	if !lit(coverHeight/2, coverHeight) {
+		t.Error("no text drawn at the bottom of the cover")
+	}
+}
+
+func TestSupported(t *testing.T) {
+	face, err := opentype.NewFace(mustParse(t), &opentype.FaceOptions{Size: 12, DPI: 72})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := supported(face, "Thám tử lừng danh"), "Thám tu lùng danh"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func mustParse(t *testing.T) *opentype.Font {
+	t.Helper()
+	f, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestParseStrategy(t *testing.T) {
+	if s, err := ParseStrategy(""); err != nil || s != Random {
+		t.Errorf("default: got %q, %v", s, err)
+	}
+	if s, err := ParseStrategy(" First-Page "); err != nil || s != FirstPage {
+		t.Errorf("got %q, %v", s, err)
+	}
+	if _, err := ParseStrategy("stock"); err == nil {
+		t.Error("unknown strategy should fail")
+	}
+}
@@ -15,19 +15,41 @@ import (
 	"github.com/vukyn/kuery/log"
 )
 
+// baseUrl returns the scheme and host used to reach a domain. Tests replace it
+// to serve recorded fixtures from a local server.
+var baseUrl = func(domain string) string {
+	return "https://" + domain
+}
+
+// httpClient is used for requests made outside of colly.
+var httpClient = http.DefaultClient
+
 type ChapterResponse struct {
 	Success  bool      `json:"success"`
 	Chapters []Chapter `json:"chapters"`
 }
 
 type Chapter struct {
-	Id   int    `json:"chapterId"`
-	Name string `json:"name"`
-	Url  string `json:"url"`
+	Id         int        `json:"chapterId"`
+	Name       string     `json:"name"`
+	Url        string     `json:"url"`
+	Number     float64    `json:"number"`
+	Volume     int        `json:"volume,omitempty"`
+	Title      string     `json:"title,omitempty"`
+	Group      string     `json:"group,omitempty"`
+	SortKey    string     `json:"sortKey"`
+	UploadedAt *time.Time `json:"uploadedAt,omitempty"`
+}
+
+// Series selects a comic on its site: nettruyen lists the chapters of a
+// comic id, qqtruyen those of a chapter list url.
+type Series struct {
+	ComicId int
+	Query   string // chapter list url of qqtruyen
 }
 
-func CrawlChapter(c *colly.Collector, domain string) ([]Chapter, error) {
-	var crawler = map[string]func(*colly.Collector) ([]Chapter, error){
+func CrawlChapter(c *colly.Collector, domain string, series Series) ([]Chapter, error) {
+	var crawler = map[string]func(*colly.Collector, Series) ([]Chapter, error){
 		env.NettruyenDomain: nettruyenChapterCallback,
 		env.QqtruyenDomain:  qqtruyenChapterCallback,
 	}
@@ -36,11 +58,29 @@ func CrawlChapter(c *colly.Collector, domain string) ([]Chapter, error) {
 		log.Errorf("Domain not supported: %s", domain)
 		return nil, fmt.Errorf("Domain not supported")
 	}
-	return callback(c)
+	chapters, err := callback(newTaskCollector(c), series)
+	if err != nil {
+		return nil, err
+	}
+	return NormalizeChapters(chapters, env.PreferredGroup), nil
+}
+
+// newTaskCollector clones the base collector without its callbacks, sharing
+// the HTTP backend and limits, and logs each visited url. Revisits are allowed
+// since the visited store is shared with every other task of the base collector.
+func newTaskCollector(c *colly.Collector) *colly.Collector {
+	task := c.Clone()
+	task.AllowURLRevisit = true
+
+	// Before making a request print "Visiting ..."
+	task.OnRequest(func(r *colly.Request) {
+		log.Infof("Visiting %s", r.URL.String())
+	})
+	return task
 }
 
-func nettruyenChapterCallback(_ *colly.Collector) ([]Chapter, error) {
-	url := fmt.Sprintf("https://www.%s/%s?comicId=%d", env.NettruyenDomain, env.NettruyenChapterQuery, env.ComicId)
+func nettruyenChapterCallback(_ *colly.Collector, series Series) ([]Chapter, error) {
+	url := fmt.Sprintf("%s/%s?comicId=%d", baseUrl("www."+env.NettruyenDomain), env.NettruyenChapterQuery, series.ComicId)
 	res, err := makeGet(url)
 	if err != nil {
 		return nil, err
@@ -66,29 +106,28 @@ func nettruyenChapterCallback(_ *colly.Collector) ([]Chapter, error) {
 	return chapterResponse.Chapters, nil
 }
 
-func qqtruyenChapterCallback(c *colly.Collector) ([]Chapter, error) {
-	// Before making a request print "Visiting ..."
-	c.OnRequest(func(r *colly.Request) {
-		log.Infof("Visiting %s", r.URL.String())
-	})
-
+func qqtruyenChapterCallback(c *colly.Collector, series Series) ([]Chapter, error) {
 	chapters := make([]Chapter, 0)
 	c.OnHTML("div.works-chapter-list", func(e *colly.HTMLElement) {
-		e.ForEach("div.works-chapter-item", func(i int, chapterItem *colly.HTMLElement) {
+		e.ForEach("div.works-chapter-item", func(_ int, chapterItem *colly.HTMLElement) {
+			var uploadedAt *time.Time
+			if t, err := time.Parse("02/01/2006", chapterItem.ChildText("div.time-chap")); err == nil {
+				uploadedAt = &t
+			}
 			chapterItem.ForEach("a", func(_ int, e1 *colly.HTMLElement) {
 				link, _ := url.Parse(e1.Attr("href"))
 				log.Infof("Chapter found: (%s) - %s", e1.Text, link.Path)
 				chapters = append(chapters, Chapter{
-					Id:   i + 1,
-					Name: e1.Text,
-					Url:  link.Path,
+					Name:       e1.Text,
+					Url:        link.Path,
+					UploadedAt: uploadedAt,
 				})
 			})
 		})
 	})
 
 	// Start scraping
-	if err := c.Visit(env.QqtruyenChapterQuery); err != nil {
+	if err := c.Visit(series.Query); err != nil {
 		log.Errorf("Error visiting: %v", err)
 		return nil, err
 	}
@@ -107,7 +146,7 @@ func makeGet(url string) ([]byte, error) {
 		return nil, err
 	}
 
-	data, err := http.DefaultClient.Do(req)
+	data, err := httpClient.Do(req)
 	if err != nil {
 		log.Errorf("Error making request: %v", err)
 		return nil, err
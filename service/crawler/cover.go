@@ -0,0 +1,82 @@
+package crawler
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"comic-crawler/env"
+
+	"github.com/gocolly/colly"
+	"github.com/vukyn/kuery/log"
+)
+
+type coverSource struct {
+	seriesPage func(Series, []Chapter) string
+	// Series artwork, tried after the og:image meta
+	selectors []string
+}
+
+// CrawlCover returns the url of the series cover, scraped from the series page.
+func CrawlCover(c *colly.Collector, domain string, series Series, chapters []Chapter) (string, error) {
+	var sources = map[string]coverSource{
+		env.NettruyenDomain: {nettruyenSeriesPage, []string{"div.col-image img", "div.detail-info img"}},
+		env.QqtruyenDomain:  {qqtruyenSeriesPage, []string{"div.book_avatar img", "div.book_detail img"}},
+	}
+	source, ok := sources[domain]
+	if !ok {
+		log.Errorf("Domain not supported: %s", domain)
+		return "", fmt.Errorf("Domain not supported")
+	}
+	pageUrl := source.seriesPage(series, chapters)
+	if pageUrl == "" {
+		return "", fmt.Errorf("series page not found")
+	}
+
+	c = newTaskCollector(c)
+	cover := ""
+	c.OnHTML("meta[property='og:image']", func(e *colly.HTMLElement) {
+		if cover == "" {
+			cover = e.Request.AbsoluteURL(e.Attr("content"))
+		}
+	})
+	for _, selector := range source.selectors {
+		c.OnHTML(selector, func(e *colly.HTMLElement) {
+			src := e.Attr("src")
+			if src == "" {
+				src = e.Attr("data-src")
+			}
+			if cover == "" && src != "" {
+				cover = e.Request.AbsoluteURL(src)
+			}
+		})
+	}
+
+	if err := c.Visit(pageUrl); err != nil {
+		log.Errorf("Error visiting: %v", err)
+		return "", err
+	}
+	if cover == "" {
+		return "", fmt.Errorf("no cover found on %s", pageUrl)
+	}
+	log.Infof("Cover found: %s", cover)
+	return cover, nil
+}
+
+// nettruyenSeriesPage derives the series page from a chapter url such as
+// /truyen-tranh/<slug>/chapter-1/1001.
+func nettruyenSeriesPage(_ Series, chapters []Chapter) string {
+	if len(chapters) == 0 {
+		return ""
+	}
+	p := path.Dir(path.Dir(strings.TrimSuffix(chapters[0].Url, "/")))
+	if p == "/" || p == "." {
+		return ""
+	}
+	return baseUrl("www."+env.NettruyenDomain) + p
+}
+
+// qqtruyenSeriesPage is the chapter list page.
+func qqtruyenSeriesPage(series Series, _ []Chapter) string {
+	return series.Query
+}
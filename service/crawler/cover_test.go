@@ -0,0 +1,53 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"comic-crawler/env"
+
+	"github.com/gocolly/colly"
+)
+
+func TestCrawlCover(t *testing.T) {
+	pages := map[string]string{
+		"/truyen-tranh/og":     `<html><head><meta property="og:image" content="/og.jpg"></head><body><div class="col-image"><img src="/img.jpg"></div></body></html>`,
+		"/truyen-tranh/img":    `<html><body><div class="col-image"><img data-src="//cdn.example.com/img.jpg"></div></body></html>`,
+		"/truyen-tranh/none":   `<html><body></body></html>`,
+		"/truyen-tranh/qq-123": `<html><body><div class="book_avatar"><img src="https://cdn.example.com/qq.jpg"></div></body></html>`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(pages[r.URL.Path]))
+	}))
+	defer srv.Close()
+
+	oldBaseUrl := baseUrl
+	defer func() { baseUrl = oldBaseUrl }()
+	baseUrl = func(string) string { return srv.URL }
+	series := Series{ComicId: 1, Query: srv.URL + "/truyen-tranh/qq-123"}
+
+	tests := []struct {
+		domain  string
+		chapter string
+		want    string
+	}{
+		{env.NettruyenDomain, "/truyen-tranh/og/chapter-1/1001", srv.URL + "/og.jpg"},
+		{env.NettruyenDomain, "/truyen-tranh/img/chapter-1/1001", "http://cdn.example.com/img.jpg"},
+		{env.NettruyenDomain, "/truyen-tranh/none/chapter-1/1001", ""},
+		{env.QqtruyenDomain, "", "https://cdn.example.com/qq.jpg"},
+	}
+	for _, tt := range tests {
+		got, err := CrawlCover(colly.NewCollector(), tt.domain, series, []Chapter{{Url: tt.chapter}})
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %s", tt.chapter, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s %s: got %q, %v, want %q", tt.domain, tt.chapter, got, err, tt.want)
+		}
+	}
+}
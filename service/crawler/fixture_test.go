@@ -0,0 +1,92 @@
+package crawler
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"comic-crawler/env"
+
+	"github.com/gocolly/colly"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if err := env.Init(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// replayFixture serves the fixture recorded in dir, points the crawler at it
+// and returns the recorded series.
+func replayFixture(t *testing.T, dir string) (*Fixture, Series) {
+	t.Helper()
+	fixture, err := ReadFixture(dir)
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	srv := httptest.NewServer(ReplayHandler(dir, fixture))
+	t.Cleanup(srv.Close)
+
+	oldBaseUrl := baseUrl
+	t.Cleanup(func() { baseUrl = oldBaseUrl })
+	baseUrl = func(string) string { return srv.URL }
+	return fixture, Series{ComicId: fixture.ComicId, Query: srv.URL + fixture.ChapterQuery}
+}
+
+func TestFixtures(t *testing.T) {
+	dirs, err := filepath.Glob("testdata/*/" + FixtureFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) == 0 {
+		t.Fatal("no fixtures found")
+	}
+	for _, path := range dirs {
+		dir := filepath.Dir(path)
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			fixture, series := replayFixture(t, dir)
+			c := colly.NewCollector()
+
+			chapters, err := CrawlChapter(c, fixture.Domain, series)
+			if err != nil {
+				t.Fatalf("crawl chapters: %v", err)
+			}
+			if fixture.ChapterIndex >= len(chapters) {
+				t.Fatalf("chapter index %d out of range (%d chapters)", fixture.ChapterIndex, len(chapters))
+			}
+			got := Golden{
+				Chapters: chapters,
+				Images:   CrawlImg(c, fixture.Domain, chapters[fixture.ChapterIndex].Url),
+			}
+
+			goldenPath := filepath.Join(dir, GoldenFile)
+			if *update {
+				if err := writeJSON(goldenPath, got); err != nil {
+					t.Fatalf("update golden: %v", err)
+				}
+			}
+			data, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("read golden: %v", err)
+			}
+			var want Golden
+			if err := json.Unmarshal(data, &want); err != nil {
+				t.Fatalf("parse golden: %v", err)
+			}
+			if !reflect.DeepEqual(got.Chapters, want.Chapters) {
+				t.Errorf("chapters:\ngot  %+v\nwant %+v", got.Chapters, want.Chapters)
+			}
+			if !reflect.DeepEqual(got.Images, want.Images) {
+				t.Errorf("images:\ngot  %v\nwant %v", got.Images, want.Images)
+			}
+		})
+	}
+}
@@ -24,17 +24,27 @@ func CrawlImg(c *colly.Collector, domain, url string) []string {
 		return nil
 	}
 
-	// Before making a request print "Visiting ..."
-	c.OnRequest(func(r *colly.Request) {
-		log.Infof("Visiting %s", r.URL.String())
-	})
+	// Use a fresh collector per chapter so callbacks and results never leak
+	// between chapters, and chapters can be crawled concurrently
+	c = newTaskCollector(c)
 
 	// Callback
 	imgCollector := &Collector{}
 	callback(c, imgCollector)
 
+	// Fallback to inline scripts when the page has no image tags
+	c.OnScraped(func(r *colly.Response) {
+		if len(imgCollector.Url) > 0 {
+			return
+		}
+		for _, link := range ExtractScriptImages(r.Body) {
+			log.Infof("Link found (script): %s", link)
+			imgCollector.Url = append(imgCollector.Url, link)
+		}
+	})
+
 	// Start scraping
-	url = "https://" + domain + url
+	url = baseUrl(domain) + url
 	if err := c.Visit(url); err != nil {
 		log.Errorf("Error visiting: %v", err)
 		return nil
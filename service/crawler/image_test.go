@@ -0,0 +1,75 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"comic-crawler/env"
+
+	"github.com/gocolly/colly"
+)
+
+func chapterPage(chapter, pages int) string {
+	var b strings.Builder
+	b.WriteString(`<html><body><div class="page-chapter">`)
+	for i := 1; i <= pages; i++ {
+		fmt.Fprintf(&b, `<img class="lozad" data-src="https://cdn.example.com/%d/%d.jpg" />`, chapter, i)
+	}
+	b.WriteString(`</div></body></html>`)
+	return b.String()
+}
+
+func chapterImgs(chapter, pages int) []string {
+	imgs := make([]string, 0, pages)
+	for i := 1; i <= pages; i++ {
+		imgs = append(imgs, fmt.Sprintf("https://cdn.example.com/%d/%d.jpg", chapter, i))
+	}
+	return imgs
+}
+
+func TestCrawlImgDoesNotLeakBetweenChapters(t *testing.T) {
+	const chapters = 4
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var chapter int
+		if _, err := fmt.Sscanf(r.URL.Path, "/chapter-%d", &chapter); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, chapterPage(chapter, chapter+1))
+	}))
+	defer srv.Close()
+
+	domain := strings.TrimPrefix(srv.URL, "https://")
+	defer func(old string) { env.NettruyenDomain = old }(env.NettruyenDomain)
+	env.NettruyenDomain = domain
+
+	c := colly.NewCollector()
+	c.WithTransport(srv.Client().Transport)
+
+	// Sequential crawl on the same base collector
+	for i := 1; i <= chapters; i++ {
+		got := CrawlImg(c, domain, fmt.Sprintf("/chapter-%d", i))
+		if want := chapterImgs(i, i+1); !reflect.DeepEqual(got, want) {
+			t.Fatalf("chapter %d: got %v, want %v", i, got, want)
+		}
+	}
+
+	// Concurrent crawl on the same base collector
+	var wg sync.WaitGroup
+	for i := 1; i <= chapters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got := CrawlImg(c, domain, fmt.Sprintf("/chapter-%d", i))
+			if want := chapterImgs(i, i+1); !reflect.DeepEqual(got, want) {
+				t.Errorf("chapter %d (concurrent): got %v, want %v", i, got, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,150 @@
+package crawler
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vukyn/kuery/log"
+)
+
+var (
+	volumeRegex     = regexp.MustCompile(`(?i)\b(?:vol(?:ume)?\.?|tập|quyển)\s*(\d+)`)
+	chapterNumRegex = regexp.MustCompile(`(?i)(?:chapter|chương|chuong|chap|ch\.?)\s*(\d+(?:[.,]\d+)?)`)
+	anyNumRegex     = regexp.MustCompile(`\d+(?:[.,]\d+)?`)
+	groupRegex      = regexp.MustCompile(`\[([^\]]+)\]`)
+	titleTrimChars  = " -:.|–—"
+)
+
+// ParseChapterName parses the volume, number, title and scanlation group out
+// of a chapter name such as "Vol.2 Chapter 12.5: Title [Group]".
+func ParseChapterName(name string) (number float64, hasNumber bool, volume int, title, group string) {
+	rest := name
+	if m := groupRegex.FindStringSubmatch(rest); m != nil {
+		group = strings.TrimSpace(m[1])
+		rest = strings.Replace(rest, m[0], "", 1)
+	}
+	if m := volumeRegex.FindStringSubmatchIndex(rest); m != nil {
+		volume, _ = strconv.Atoi(rest[m[2]:m[3]])
+		rest = rest[:m[0]] + rest[m[1]:]
+	}
+
+	loc := chapterNumRegex.FindStringSubmatchIndex(rest)
+	if loc == nil {
+		if m := anyNumRegex.FindStringIndex(rest); m != nil {
+			loc = []int{m[0], m[1], m[0], m[1]}
+		}
+	}
+	if loc != nil {
+		n, err := strconv.ParseFloat(strings.Replace(rest[loc[2]:loc[3]], ",", ".", 1), 64)
+		if err == nil {
+			number, hasNumber = n, true
+			rest = rest[loc[1]:]
+		}
+	}
+
+	title = strings.Trim(strings.TrimSpace(rest), titleTrimChars)
+	return number, hasNumber, volume, title, group
+}
+
+// NormalizeChapters parses every chapter name, sorts chapters in ascending
+// reading order and drops duplicate uploads of the same chapter. Between
+// duplicates the one from the preferred group wins, then the newest upload,
+// then the first listed by the site. Chapters without an id get their
+// position in reading order.
+func NormalizeChapters(chapters []Chapter, preferredGroup string) []Chapter {
+	for i := range chapters {
+		c := &chapters[i]
+		number, hasNumber, volume, title, group := ParseChapterName(c.Name)
+		c.Number = number
+		c.Volume = volume
+		c.Title = title
+		if c.Group == "" {
+			c.Group = group
+		}
+		c.SortKey = sortKey(hasNumber, number, volume, title != "", i)
+	}
+
+	// Resolve duplicates, keeping the position of the first occurrence
+	keep := make([]Chapter, 0, len(chapters))
+	index := make(map[string]int)
+	for _, c := range chapters {
+		key := fmt.Sprintf("%d|%v|%s", c.Volume, c.Number, strings.ToLower(c.Title))
+		if !hasChapterNumber(c) {
+			keep = append(keep, c)
+			continue
+		}
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(keep)
+			keep = append(keep, c)
+			continue
+		}
+		if preferChapter(c, keep[i], preferredGroup) {
+			log.Warnf("Duplicate chapter %s, replacing %s", c.Name, keep[i].Name)
+			c.SortKey = keep[i].SortKey
+			keep[i] = c
+		} else {
+			log.Warnf("Duplicate chapter %s, skipping...", c.Name)
+		}
+	}
+
+	sort.SliceStable(keep, func(i, j int) bool {
+		return keep[i].SortKey < keep[j].SortKey
+	})
+	// Sites without chapter ids are numbered in reading order
+	for i := range keep {
+		if keep[i].Id == 0 {
+			keep[i].Id = i + 1
+		}
+	}
+	return keep
+}
+
+// ChapterSortKey returns the reading order key of a chapter name, pos being
+// its position in the listing. Converted outputs, readers and catalogs order
+// chapter folders with it like the crawler orders chapters.
+func ChapterSortKey(name string, pos int) string {
+	number, hasNumber, volume, title, _ := ParseChapterName(name)
+	return sortKey(hasNumber, number, volume, title != "", pos)
+}
+
+// sortKey orders numbered chapters by volume, number, then plain chapters
+// before titled extras of the same number, and keeps unnumbered chapters
+// after them in the order listed by the site. Chapters without a volume come
+// after the volumes, as sites list the chapters not collected yet last.
+func sortKey(hasNumber bool, number float64, volume int, hasTitle bool, pos int) string {
+	if !hasNumber {
+		return fmt.Sprintf("~%06d", pos)
+	}
+	extra := 0
+	if hasTitle {
+		extra = 1
+	}
+	if volume == 0 {
+		volume = 9999
+	}
+	whole, frac := math.Modf(number)
+	return fmt.Sprintf("%04d-%08d.%04d-%d", volume, int(whole), int(math.Round(frac*10000)), extra)
+}
+
+func hasChapterNumber(c Chapter) bool {
+	return !strings.HasPrefix(c.SortKey, "~")
+}
+
+func preferChapter(c, current Chapter, preferredGroup string) bool {
+	if preferredGroup != "" {
+		isPreferred := strings.EqualFold(c.Group, preferredGroup)
+		isCurrentPreferred := strings.EqualFold(current.Group, preferredGroup)
+		if isPreferred != isCurrentPreferred {
+			return isPreferred
+		}
+	}
+	if c.UploadedAt != nil && current.UploadedAt != nil {
+		return c.UploadedAt.After(*current.UploadedAt)
+	}
+	return c.UploadedAt != nil && current.UploadedAt == nil
+}
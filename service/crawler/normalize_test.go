@@ -0,0 +1,88 @@
+package crawler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseChapterName(t *testing.T) {
+	tests := []struct {
+		name   string
+		number float64
+		has    bool
+		volume int
+		title  string
+		group  string
+	}{
+		{"Chapter 12", 12, true, 0, "", ""},
+		{"Chapter 12.5", 12.5, true, 0, "", ""},
+		{"Chương 12 - Extra", 12, true, 0, "Extra", ""},
+		{"Vol.2 Chapter 13: The Return", 13, true, 2, "The Return", ""},
+		{"Tập 3 Chương 20", 20, true, 3, "", ""},
+		{"Chap 7 [TeamA]", 7, true, 0, "", "TeamA"},
+		{"42", 42, true, 0, "", ""},
+		{"Oneshot", 0, false, 0, "Oneshot", ""},
+	}
+	for _, tt := range tests {
+		number, has, volume, title, group := ParseChapterName(tt.name)
+		if number != tt.number || has != tt.has || volume != tt.volume || title != tt.title || group != tt.group {
+			t.Errorf("ParseChapterName(%q) = %v, %v, %v, %q, %q", tt.name, number, has, volume, title, group)
+		}
+	}
+}
+
+func TestNormalizeChapters(t *testing.T) {
+	older := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.AddDate(0, 0, 7)
+
+	// Newest first, as listed by the sites
+	chapters := []Chapter{
+		{Name: "Oneshot"},
+		{Name: "Chương 12 - Extra"},
+		{Name: "Chương 12", UploadedAt: &newer},
+		{Name: "Chương 12 [TeamB]", UploadedAt: &older},
+		{Name: "Chương 11.5"},
+		{Name: "Chương 2"},
+		{Name: "Chương 10"},
+	}
+
+	got := NormalizeChapters(append([]Chapter{}, chapters...), "")
+	want := []string{"Chương 2", "Chương 10", "Chương 11.5", "Chương 12", "Chương 12 - Extra", "Oneshot"}
+	assertChapterNames(t, got, want)
+
+	got = NormalizeChapters(append([]Chapter{}, chapters...), "teamb")
+	want = []string{"Chương 2", "Chương 10", "Chương 11.5", "Chương 12 [TeamB]", "Chương 12 - Extra", "Oneshot"}
+	assertChapterNames(t, got, want)
+	for i, c := range got {
+		if c.Id != i+1 {
+			t.Errorf("%s: id %d, want %d", c.Name, c.Id, i+1)
+		}
+	}
+
+	// Numbering restarts with each volume, chapters not collected yet come last
+	chapters = []Chapter{
+		{Name: "Chapter 13"},
+		{Name: "Vol.2 Chapter 1", Id: 7},
+		{Name: "Vol.1 Chapter 5"},
+		{Name: "Vol.10 Chapter 2"},
+		{Name: "Vol.1 Chapter 1"},
+	}
+	got = NormalizeChapters(chapters, "")
+	want = []string{"Vol.1 Chapter 1", "Vol.1 Chapter 5", "Vol.2 Chapter 1", "Vol.10 Chapter 2", "Chapter 13"}
+	assertChapterNames(t, got, want)
+	if got[2].Id != 7 {
+		t.Errorf("site id replaced: %d", got[2].Id)
+	}
+}
+
+func assertChapterNames(t *testing.T, chapters []Chapter, want []string) {
+	t.Helper()
+	if len(chapters) != len(want) {
+		t.Fatalf("got %d chapters, want %d", len(chapters), len(want))
+	}
+	for i := range want {
+		if chapters[i].Name != want[i] {
+			t.Errorf("chapter %d: got %q, want %q", i, chapters[i].Name, want[i])
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gocolly/colly"
+	"github.com/vukyn/kuery/log"
+)
+
+// Fixture describes a recorded crawl which can be replayed offline.
+type Fixture struct {
+	Domain       string            `json:"domain"`
+	ComicId      int               `json:"comicId"`
+	ChapterQuery string            `json:"chapterQuery"` // request uri of the chapter list
+	ChapterIndex int               `json:"chapterIndex"` // chapter whose page was recorded
+	Responses    map[string]string `json:"responses"`    // request uri -> response file
+}
+
+// Golden holds the expected crawl result of a fixture.
+type Golden struct {
+	Chapters []Chapter `json:"chapters"`
+	Images   []string  `json:"images"`
+}
+
+const (
+	FixtureFile = "fixture.json"
+	GoldenFile  = "golden.json"
+)
+
+// Record crawls the chapter list and the first chapter page of a comic from
+// a live site, and writes every response together with the crawl
+// result into dir, for later offline replay.
+func Record(c *colly.Collector, domain string, series Series, dir string) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	recorder := &recordTransport{
+		base:      http.DefaultTransport,
+		dir:       dir,
+		responses: make(map[string]string),
+	}
+	c.WithTransport(recorder)
+	defer func(client *http.Client) { httpClient = client }(httpClient)
+	httpClient = &http.Client{Transport: recorder}
+
+	chapters, err := CrawlChapter(c, domain, series)
+	if err != nil {
+		return err
+	}
+	if len(chapters) == 0 {
+		return fmt.Errorf("no chapters found")
+	}
+	chapterQuery := recorder.first
+
+	imgs := CrawlImg(c, domain, chapters[0].Url)
+	if len(imgs) == 0 {
+		return fmt.Errorf("no images found")
+	}
+
+	fixture := Fixture{
+		Domain:       domain,
+		ComicId:      series.ComicId,
+		ChapterQuery: chapterQuery,
+		ChapterIndex: 0,
+		Responses:    recorder.responses,
+	}
+	if err := writeJSON(filepath.Join(dir, FixtureFile), fixture); err != nil {
+		return err
+	}
+	golden := Golden{
+		Chapters: chapters,
+		Images:   imgs,
+	}
+	if err := writeJSON(filepath.Join(dir, GoldenFile), golden); err != nil {
+		return err
+	}
+	log.Infof("Recorded %d responses into %s", len(recorder.responses), dir)
+	return nil
+}
+
+// ReadFixture loads a recorded fixture from dir.
+func ReadFixture(dir string) (*Fixture, error) {
+	data, err := os.ReadFile(filepath.Join(dir, FixtureFile))
+	if err != nil {
+		return nil, err
+	}
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, err
+	}
+	return &fixture, nil
+}
+
+// ReplayHandler serves the responses of a fixture recorded in dir.
+func ReplayHandler(dir string, fixture *Fixture) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := fixture.Responses[r.URL.RequestURI()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if strings.HasSuffix(name, ".json") {
+			w.Header().Set("Content-Type", "application/json")
+		} else {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		}
+		w.Write(data)
+	})
+}
+
+// recordTransport saves every successful response body into dir.
+type recordTransport struct {
+	base      http.RoundTripper
+	dir       string
+	mu        sync.Mutex
+	first     string
+	responses map[string]string
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.base.RoundTrip(req)
+	if err != nil || res.StatusCode != http.StatusOK {
+		return res, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	uri := req.URL.RequestURI()
+	ext := ".html"
+	if strings.Contains(res.Header.Get("Content-Type"), "json") {
+		ext = ".json"
+	}
+	name := fmt.Sprintf("%03d%s", len(t.responses)+1, ext)
+	if err := os.WriteFile(filepath.Join(t.dir, name), body, 0644); err != nil {
+		return nil, err
+	}
+	if t.first == "" {
+		t.first = uri
+	}
+	t.responses[uri] = name
+	return res, nil
+}
+
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
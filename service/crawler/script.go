@@ -0,0 +1,151 @@
+package crawler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/vukyn/kuery/log"
+)
+
+// ScriptDecoder turns the source of an inline script into more script source,
+// for example by unpacking or base64 decoding it. Decoded output is scanned
+// for image urls the same way as the original script.
+type ScriptDecoder func(script string) []string
+
+var scriptDecoders = []ScriptDecoder{
+	unpackDecoder,
+	base64Decoder,
+}
+
+var (
+	scriptRegex      = regexp.MustCompile(`(?is)<script[^>]*>(.*?)</script>`)
+	packerRegex      = regexp.MustCompile(`(?s)}\s*\(\s*'((?:\\.|[^'\\])*)'\s*,\s*(\d+)\s*,\s*(\d+)\s*,\s*'((?:\\.|[^'\\])*)'\.split\(\s*'\|'\s*\)`)
+	packerWordRegex  = regexp.MustCompile(`\b\w+\b`)
+	base64Regex      = regexp.MustCompile(`["']([A-Za-z0-9+/]{16,}={0,2})["']`)
+	stringArrayRegex = regexp.MustCompile(`\[\s*(?:"(?:\\.|[^"\\])*"\s*,?\s*)+\]`)
+	imgUrlRegex      = regexp.MustCompile(`(?i)(?:https?:)?//[^\s"'<>()\\,]+?\.(?:jpe?g|png|webp|gif|avif)(?:\?[^\s"'<>()\\,]*)?`)
+)
+
+// RegisterScriptDecoder adds a decoder used by ExtractScriptImages.
+func RegisterScriptDecoder(decoder ScriptDecoder) {
+	scriptDecoders = append(scriptDecoders, decoder)
+}
+
+// ExtractScriptImages finds image urls embedded in the inline scripts of a page,
+// either as plain arrays, base64 blobs or packed javascript.
+func ExtractScriptImages(body []byte) []string {
+	urls := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, match := range scriptRegex.FindAllSubmatch(body, -1) {
+		for _, u := range scanScript(string(match[1]), 2) {
+			if seen[u] {
+				continue
+			}
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// scanScript collects image urls from a script and from everything the
+// registered decoders produce out of it, up to depth levels of decoding.
+func scanScript(script string, depth int) []string {
+	urls := findImgUrls(script)
+	if depth == 0 {
+		return urls
+	}
+	for _, decoder := range scriptDecoders {
+		for _, decoded := range decoder(script) {
+			urls = append(urls, scanScript(decoded, depth-1)...)
+		}
+	}
+	return urls
+}
+
+func findImgUrls(script string) []string {
+	script = strings.ReplaceAll(script, `\/`, `/`)
+	urls := make([]string, 0)
+
+	// JSON string arrays keep their original order, so prefer them
+	for _, arr := range stringArrayRegex.FindAllString(script, -1) {
+		var items []string
+		if err := json.Unmarshal([]byte(arr), &items); err != nil {
+			continue
+		}
+		for _, item := range items {
+			if imgUrlRegex.MatchString(item) {
+				urls = append(urls, normalizeImgUrl(imgUrlRegex.FindString(item)))
+			}
+		}
+	}
+
+	for _, u := range imgUrlRegex.FindAllString(script, -1) {
+		urls = append(urls, normalizeImgUrl(u))
+	}
+	return urls
+}
+
+func normalizeImgUrl(u string) string {
+	if strings.HasPrefix(u, "//") {
+		return "https:" + u
+	}
+	return u
+}
+
+// unpackDecoder reverses Dean Edwards' packer: eval(function(p,a,c,k,e,d){...}('payload',base,count,'words'.split('|'),0,{}))
+func unpackDecoder(script string) []string {
+	res := make([]string, 0)
+	for _, match := range packerRegex.FindAllStringSubmatch(script, -1) {
+		payload := unescapeJS(match[1])
+		base, _ := strconv.Atoi(match[2])
+		words := strings.Split(unescapeJS(match[4]), "|")
+		if base < 2 || base > 62 {
+			log.Warnf("Unsupported packer base: %d", base)
+			continue
+		}
+		unpacked := packerWordRegex.ReplaceAllStringFunc(payload, func(token string) string {
+			i, ok := parseBase(token, base)
+			if !ok || i >= len(words) || words[i] == "" {
+				return token
+			}
+			return words[i]
+		})
+		res = append(res, unpacked)
+	}
+	return res
+}
+
+// base64Decoder decodes quoted base64 literals which contain readable text.
+func base64Decoder(script string) []string {
+	res := make([]string, 0)
+	for _, match := range base64Regex.FindAllStringSubmatch(script, -1) {
+		decoded, err := base64.StdEncoding.DecodeString(match[1])
+		if err != nil || !utf8.Valid(decoded) {
+			continue
+		}
+		res = append(res, string(decoded))
+	}
+	return res
+}
+
+func parseBase(token string, base int) (int, bool) {
+	const digits = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	n := 0
+	for _, r := range token {
+		d := strings.IndexRune(digits, r)
+		if d < 0 || d >= base {
+			return 0, false
+		}
+		n = n*base + d
+	}
+	return n, true
+}
+
+func unescapeJS(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\'`, `'`, `\"`, `"`).Replace(s)
+}
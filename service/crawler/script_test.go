@@ -0,0 +1,63 @@
+package crawler
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExtractScriptImages(t *testing.T) {
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{"inline.html", []string{
+			"https://cdn.example.com/comic/1/001.jpg",
+			"https://cdn.example.com/comic/1/002.jpg",
+			"https://cdn.example.com/comic/1/003.webp?token=abc",
+		}},
+		{"base64.html", []string{
+			"https://cdn.example.com/comic/2/001.png",
+			"https://cdn.example.com/comic/2/002.png",
+		}},
+		{"packed.html", []string{
+			"https://cdn.example.com/comic/3/001.jpg",
+			"https://cdn.example.com/comic/3/002.jpg",
+			"https://cdn.example.com/comic/3/003.jpg",
+		}},
+		// A base64 payload inside a packed script is decoded twice
+		{"packed_base64.html", []string{
+			"https://cdn.example.com/comic/4/001.jpg",
+		}},
+		{"nomatch.html", []string{}},
+	}
+	for _, tt := range tests {
+		body, err := os.ReadFile(filepath.Join("testdata", "scripts", tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ExtractScriptImages(body); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestUnpackDecoder(t *testing.T) {
+	script := `eval(function(p,a,c,k,e,d){return p}('0 1=2;',10,3,'var|pages|x'.split('|'),0,{}))`
+	if got := unpackDecoder(script); !reflect.DeepEqual(got, []string{"var pages=x;"}) {
+		t.Errorf("got %q", got)
+	}
+	// Unsupported bases are skipped
+	script = `eval(function(p,a,c,k,e,d){return p}('0 1=2;',99,3,'var|pages|x'.split('|'),0,{}))`
+	if got := unpackDecoder(script); len(got) != 0 {
+		t.Errorf("base 99: got %q", got)
+	}
+}
+
+func TestBase64Decoder(t *testing.T) {
+	script := `var a = "aHR0cHM6Ly9jZG4uZXhhbXBsZS5jb20vMS5qcGc="; var b = "//////////////////////8=";`
+	if got := base64Decoder(script); !reflect.DeepEqual(got, []string{"https://cdn.example.com/1.jpg"}) {
+		t.Errorf("got %q", got)
+	}
+}
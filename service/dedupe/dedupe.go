@@ -0,0 +1,290 @@
+package dedupe
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"comic-crawler/service/imaging"
+
+	"github.com/anthonynsimon/bild/imgio"
+	"github.com/vukyn/kuery/log"
+)
+
+const (
+	HashFile   = "hashes.json"
+	ReportFile = "removed.json"
+)
+
+type Option struct {
+	MinChapters int    // pages repeated in at least this many chapters are ads
+	Distance    int    // max Hamming distance between hashes of the same page
+	Blocklist   string // path of a file with one hex hash per line
+	// DryRun detects the pages without writing the hash cache nor the report
+	DryRun bool
+}
+
+// Removed is a page detected as an advertisement or duplicate.
+type Removed struct {
+	Chapter string `json:"chapter"`
+	Page    string `json:"page"`
+	Hash    string `json:"hash"`
+	Reason  string `json:"reason"`
+}
+
+// Skip holds the pages to skip for each chapter folder.
+type Skip map[string]map[string]bool
+
+type cachedHash struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"modTime"`
+	Hash    string `json:"hash"`
+}
+
+type page struct {
+	chapter string
+	name    string
+	hash    uint64
+}
+
+// LoadBlocklist reads hex hashes, one per line, ignoring blank lines and
+// comments starting with #. A missing file is an empty blocklist.
+func LoadBlocklist(path string) ([]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	hashes := make([]uint64, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.SplitN(scanner.Text(), "#", 2)[0])
+		if line == "" {
+			continue
+		}
+		hash, err := imaging.ParseHash(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hash %q in %s: %v", line, path, err)
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, scanner.Err()
+}
+
+// IsBlocked reports whether the image at path matches a blocklisted hash.
+func IsBlocked(path string, blocklist []uint64, distance int) (bool, error) {
+	if len(blocklist) == 0 {
+		return false, nil
+	}
+	img, err := imgio.Open(path)
+	if err != nil {
+		return false, err
+	}
+	return matchAny(imaging.Hash(img), blocklist, distance), nil
+}
+
+// Scan hashes every page of every chapter under comicPath and returns the
+// pages which are blocklisted or repeated across chapters. Hashes are cached
+// in comicPath, and the removed pages are written to a report, unless
+// opt.DryRun is set.
+func Scan(comicPath string, chapters []string, opt Option) (Skip, []Removed, error) {
+	blocklist, err := LoadBlocklist(opt.Blocklist)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pages, err := hashPages(comicPath, chapters, !opt.DryRun)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	groups := groupPages(pages, opt.Distance)
+	skip := make(Skip)
+	removed := make([]Removed, 0)
+	for i, p := range pages {
+		reason := ""
+		if matchAny(p.hash, blocklist, opt.Distance) {
+			reason = "blocklist"
+		} else if !isUniform(p.hash) && opt.MinChapters > 1 {
+			// Count distinct chapters containing the same page
+			seen := map[string]bool{p.chapter: true}
+			groups.each(i, func(other page) {
+				if !seen[other.chapter] && imaging.Distance(p.hash, other.hash) <= opt.Distance {
+					seen[other.chapter] = true
+				}
+			})
+			if len(seen) >= opt.MinChapters {
+				reason = fmt.Sprintf("repeated in %d chapters", len(seen))
+			}
+		}
+		if reason == "" {
+			continue
+		}
+
+		if skip[p.chapter] == nil {
+			skip[p.chapter] = make(map[string]bool)
+		}
+		skip[p.chapter][p.name] = true
+		removed = append(removed, Removed{
+			Chapter: p.chapter,
+			Page:    p.name,
+			Hash:    imaging.FormatHash(p.hash),
+			Reason:  reason,
+		})
+	}
+
+	if opt.DryRun {
+		return skip, removed, nil
+	}
+	if err := writeJSON(filepath.Join(comicPath, ReportFile), removed); err != nil {
+		return nil, nil, err
+	}
+	return skip, removed, nil
+}
+
+// hashPages hashes the images of each chapter, reusing cached hashes of
+// files which did not change. New hashes are cached if write is set.
+func hashPages(comicPath string, chapters []string, write bool) ([]page, error) {
+	cachePath := filepath.Join(comicPath, HashFile)
+	cache := make(map[string]cachedHash)
+	if data, err := os.ReadFile(cachePath); err == nil {
+		if err := json.Unmarshal(data, &cache); err != nil {
+			log.Warnf("Ignoring invalid hash cache %s: %v", cachePath, err)
+			cache = make(map[string]cachedHash)
+		}
+	}
+
+	pages := make([]page, 0)
+	for _, chapter := range chapters {
+		files, err := os.ReadDir(filepath.Join(comicPath, chapter))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if f.IsDir() || !isPage(f.Name()) {
+				continue
+			}
+			info, err := f.Info()
+			if err != nil {
+				return nil, err
+			}
+
+			key := chapter + "/" + f.Name()
+			cached, ok := cache[key]
+			if !ok || cached.Size != info.Size() || cached.ModTime != info.ModTime().Unix() {
+				img, err := imgio.Open(filepath.Join(comicPath, chapter, f.Name()))
+				if err != nil {
+					log.Warnf("Failed to hash %s: %v", key, err)
+					continue
+				}
+				cached = cachedHash{
+					Size:    info.Size(),
+					ModTime: info.ModTime().Unix(),
+					Hash:    imaging.FormatHash(imaging.Hash(img)),
+				}
+				cache[key] = cached
+			}
+			hash, err := imaging.ParseHash(cached.Hash)
+			if err != nil {
+				return nil, err
+			}
+			pages = append(pages, page{chapter: chapter, name: f.Name(), hash: hash})
+		}
+	}
+
+	if !write {
+		return pages, nil
+	}
+	return pages, writeJSON(cachePath, cache)
+}
+
+// groups buckets pages by segments of their hash. Hashes at most distance
+// bits apart differ in at most distance of distance+1 segments, so they share
+// at least one segment exactly and only pages of the same buckets need
+// comparing.
+type groups struct {
+	pages    []page
+	segments int
+	buckets  []map[uint64][]int
+}
+
+func groupPages(pages []page, distance int) groups {
+	g := groups{pages: pages, segments: distance + 1}
+	if distance < 0 || distance >= 64 {
+		// Every page matches, or none does
+		return g
+	}
+	g.buckets = make([]map[uint64][]int, g.segments)
+	for s := range g.buckets {
+		g.buckets[s] = make(map[uint64][]int)
+		for i, p := range pages {
+			key := g.segment(p.hash, s)
+			g.buckets[s][key] = append(g.buckets[s][key], i)
+		}
+	}
+	return g
+}
+
+// segment returns the bits of segment s of hash.
+func (g groups) segment(hash uint64, s int) uint64 {
+	from, to := s*64/g.segments, (s+1)*64/g.segments
+	return hash << (64 - to) >> (64 - to + from)
+}
+
+// each calls fn once for every other page which may be near page i.
+func (g groups) each(i int, fn func(page)) {
+	if g.buckets == nil {
+		for j, p := range g.pages {
+			if j != i {
+				fn(p)
+			}
+		}
+		return
+	}
+	seen := map[int]bool{i: true}
+	for s, bucket := range g.buckets {
+		for _, j := range bucket[g.segment(g.pages[i].hash, s)] {
+			if !seen[j] {
+				seen[j] = true
+				fn(g.pages[j])
+			}
+		}
+	}
+}
+
+func matchAny(hash uint64, hashes []uint64, distance int) bool {
+	for _, h := range hashes {
+		if imaging.Distance(hash, h) <= distance {
+			return true
+		}
+	}
+	return false
+}
+
+// isPage reports whether a file is a downloaded page, named after its number.
+func isPage(name string) bool {
+	_, err := strconv.Atoi(strings.TrimSuffix(name, ".jpg"))
+	return err == nil && strings.HasSuffix(name, ".jpg")
+}
+
+// isUniform reports whether a hash comes from a blank or flat page, which
+// legitimately repeats across chapters.
+func isUniform(hash uint64) bool {
+	return hash == 0 || hash == ^uint64(0)
+}
+
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
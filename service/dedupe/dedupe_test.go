@@ -0,0 +1,245 @@
+package dedupe
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"comic-crawler/service/imaging"
+
+	"github.com/anthonynsimon/bild/imgio"
+)
+
+// pattern draws 9x8 random gray blocks of 10px, whose hash survives JPEG.
+func pattern(seed int64) image.Image {
+	r := rand.New(rand.NewSource(seed))
+	img := image.NewGray(image.Rect(0, 0, 90, 80))
+	for by := 0; by < 8; by++ {
+		for bx := 0; bx < 9; bx++ {
+			c := color.Gray{Y: uint8(r.Intn(256))}
+			for y := by * 10; y < by*10+10; y++ {
+				for x := bx * 10; x < bx*10+10; x++ {
+					img.SetGray(x, y, c)
+				}
+			}
+		}
+	}
+	return img
+}
+
+func blank() image.Image {
+	img := image.NewGray(image.Rect(0, 0, 90, 80))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	return img
+}
+
+func writePage(t *testing.T, path string, img image.Image) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	chapters := []string{"Chapter 1", "Chapter 2", "Chapter 3", "Chapter 4"}
+	for i, chapter := range chapters {
+		writePage(t, filepath.Join(dir, chapter, "1.jpg"), pattern(int64(i+1)))
+		writePage(t, filepath.Join(dir, chapter, "2.jpg"), pattern(100)) // ad
+		writePage(t, filepath.Join(dir, chapter, "3.jpg"), blank())
+		if i < 2 {
+			// Repeated in fewer than MinChapters
+			writePage(t, filepath.Join(dir, chapter, "4.jpg"), pattern(200))
+		}
+	}
+	// Not a page
+	writePage(t, filepath.Join(dir, "Chapter 1", "cover.jpg"), pattern(100))
+
+	blocklist := filepath.Join(dir, "blocklist.txt")
+	data := "# known ads\n\n" + imaging.FormatHash(imaging.Hash(pattern(3))) + " # chapter 3 page\n"
+	if err := os.WriteFile(blocklist, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	skip, removed, err := Scan(dir, chapters, Option{MinChapters: 3, Distance: 4, Blocklist: blocklist})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Skip{
+		"Chapter 1": {"2.jpg": true},
+		"Chapter 2": {"2.jpg": true},
+		"Chapter 3": {"1.jpg": true, "2.jpg": true},
+		"Chapter 4": {"2.jpg": true},
+	}
+	if !reflect.DeepEqual(skip, want) {
+		t.Errorf("Scan() skip = %v, want %v", skip, want)
+	}
+
+	reasons := make(map[string]string)
+	for _, r := range removed {
+		reasons[r.Chapter+"/"+r.Page] = r.Reason
+	}
+	wantReasons := map[string]string{
+		"Chapter 1/2.jpg": "repeated in 4 chapters",
+		"Chapter 2/2.jpg": "repeated in 4 chapters",
+		"Chapter 3/1.jpg": "blocklist",
+		"Chapter 3/2.jpg": "repeated in 4 chapters",
+		"Chapter 4/2.jpg": "repeated in 4 chapters",
+	}
+	if !reflect.DeepEqual(reasons, wantReasons) {
+		t.Errorf("Scan() reasons = %v, want %v", reasons, wantReasons)
+	}
+
+	var got []Removed
+	report, err := os.ReadFile(filepath.Join(dir, ReportFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(report, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, removed) {
+		t.Errorf("%s = %v, want %v", ReportFile, got, removed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, HashFile)); err != nil {
+		t.Errorf("hash cache not written: %v", err)
+	}
+
+	// Cached hashes give the same result
+	again, _, err := Scan(dir, chapters, Option{MinChapters: 3, Distance: 4, Blocklist: blocklist})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, want) {
+		t.Errorf("Scan() from cache = %v, want %v", again, want)
+	}
+}
+
+func TestScanDryRun(t *testing.T) {
+	dir := t.TempDir()
+	chapters := []string{"Chapter 1", "Chapter 2"}
+	for _, chapter := range chapters {
+		writePage(t, filepath.Join(dir, chapter, "1.jpg"), pattern(100))
+	}
+
+	skip, removed, err := Scan(dir, chapters, Option{MinChapters: 2, Distance: 4, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skip) != 2 || len(removed) != 2 {
+		t.Errorf("Scan() = %v, %v, want the page skipped in both chapters", skip, removed)
+	}
+	for _, name := range []string{HashFile, ReportFile} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s written in dry run", name)
+		}
+	}
+}
+
+func TestGroups(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	pages := make([]page, 200)
+	for i := range pages {
+		pages[i] = page{chapter: string(rune('a' + i%26)), hash: r.Uint64()}
+	}
+	// Near copies with bits flipped across segments
+	pages[10].hash = pages[5].hash ^ (1 | 1<<20 | 1<<40 | 1<<63)
+	pages[11].hash = pages[5].hash ^ 0xf
+
+	for _, distance := range []int{0, 4, 10, 64} {
+		g := groupPages(pages, distance)
+		for i := range pages {
+			got := 0
+			g.each(i, func(other page) {
+				if imaging.Distance(pages[i].hash, other.hash) <= distance {
+					got++
+				}
+			})
+			want := 0
+			for j := range pages {
+				if i != j && imaging.Distance(pages[i].hash, pages[j].hash) <= distance {
+					want++
+				}
+			}
+			if got != want {
+				t.Errorf("distance %d, page %d: %d near pages, want %d", distance, i, got, want)
+			}
+		}
+	}
+}
+
+func TestLoadBlocklist(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "blocklist.txt")
+	if err := os.WriteFile(path, []byte("# ads\n00000000000000ff\n\n  f0f0f0f0f0f0f0f0  # banner\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadBlocklist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint64{0xff, 0xf0f0f0f0f0f0f0f0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadBlocklist() = %x, want %x", got, want)
+	}
+
+	if got, err := LoadBlocklist(filepath.Join(dir, "missing.txt")); err != nil || got != nil {
+		t.Errorf("LoadBlocklist(missing) = %v, %v, want nil, nil", got, err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.txt")
+	if err := os.WriteFile(invalid, []byte("not a hash\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadBlocklist(invalid); err == nil {
+		t.Error("LoadBlocklist(invalid) succeeded, want error")
+	}
+}
+
+func TestIsBlocked(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "1.jpg")
+	writePage(t, path, pattern(1))
+	img, err := imgio.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := imaging.Hash(img)
+
+	tests := []struct {
+		name      string
+		blocklist []uint64
+		distance  int
+		want      bool
+	}{
+		{"empty", nil, 4, false},
+		{"exact", []uint64{hash}, 0, true},
+		{"within distance", []uint64{hash ^ 0b111}, 4, true},
+		{"too far", []uint64{hash ^ 0b11111}, 4, false},
+		{"other page", []uint64{imaging.Hash(pattern(2))}, 4, false},
+	}
+	for _, tt := range tests {
+		got, err := IsBlocked(path, tt.blocklist, tt.distance)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: IsBlocked() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package doctor
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"slices"
+
+	"comic-crawler/env"
+	"comic-crawler/service/crawler"
+	"comic-crawler/service/downloader"
+
+	"github.com/gocolly/colly"
+	"github.com/vukyn/kuery/log"
+	// Sites serve WebP pages
+	_ "golang.org/x/image/webp"
+)
+
+type Step string
+
+const (
+	StepChapters Step = "chapter listing"
+	StepPages    Step = "page extraction"
+	StepDownload Step = "image download"
+)
+
+// Source is a supported site together with a known series used to check it.
+type Source struct {
+	Name   string
+	Domain string
+	// Known series, zero when none is configured
+	Series crawler.Series
+	// Known chapter name of the series, the first listed chapter when empty
+	Chapter string
+}
+
+type Result struct {
+	Source   Source
+	Skipped  bool
+	Step     Step // step which failed, empty when every step passed
+	Err      error
+	Chapters int
+	Chapter  string // chapter whose pages were extracted
+	Images   int
+	Width    int
+	Height   int
+}
+
+func (r Result) Ok() bool {
+	return r.Err == nil
+}
+
+// Sources returns every supported source configured from env.
+func Sources() []Source {
+	return []Source{
+		{
+			Name:    "nettruyen",
+			Domain:  env.NettruyenDomain,
+			Series:  crawler.Series{ComicId: env.NettruyenCheckComicId},
+			Chapter: env.NettruyenCheckChapter,
+		},
+		{
+			Name:    "qqtruyen",
+			Domain:  env.QqtruyenDomain,
+			Series:  crawler.Series{Query: env.QqtruyenCheckChapterQuery},
+			Chapter: env.QqtruyenCheckChapter,
+		},
+	}
+}
+
+// Check runs chapter listing, page extraction and a sample image download
+// against the known series of a source, stopping at the first broken step.
+func Check(source Source) Result {
+	res := Result{Source: source}
+	if source.Series == (crawler.Series{}) {
+		res.Skipped = true
+		return res
+	}
+
+	c := colly.NewCollector(
+		colly.AllowedDomains(source.Domain, "www."+source.Domain),
+	)
+
+	chapters, err := crawler.CrawlChapter(c, source.Domain, source.Series)
+	if err == nil && len(chapters) == 0 {
+		err = fmt.Errorf("no chapters found")
+	}
+	if err != nil {
+		return res.fail(StepChapters, err)
+	}
+	res.Chapters = len(chapters)
+
+	chapter := chapters[0]
+	if source.Chapter != "" {
+		i := slices.IndexFunc(chapters, func(c crawler.Chapter) bool { return c.Name == source.Chapter })
+		if i < 0 {
+			return res.fail(StepChapters, fmt.Errorf("known chapter %q not listed", source.Chapter))
+		}
+		chapter = chapters[i]
+	}
+	res.Chapter = chapter.Name
+	urls := crawler.CrawlImg(c, source.Domain, chapter.Url)
+	if len(urls) == 0 {
+		return res.fail(StepPages, fmt.Errorf("no images found in %s", chapter.Name))
+	}
+	res.Images = len(urls)
+
+	w, h, err := downloadSample(source.Domain, urls[0])
+	if err != nil {
+		return res.fail(StepDownload, err)
+	}
+	res.Width, res.Height = w, h
+	return res
+}
+
+// Report logs the result of every check and returns an error when any failed
+// or when every source was skipped, so nothing was checked.
+func Report(results []Result) error {
+	failed, skipped := 0, 0
+	for _, res := range results {
+		switch {
+		case res.Skipped:
+			skipped++
+			log.Warnf("[SKIP] %s (%s): no known series configured", res.Source.Name, res.Source.Domain)
+		case !res.Ok():
+			failed++
+			log.Errorf("[FAIL] %s (%s): %s broken: %v", res.Source.Name, res.Source.Domain, res.Step, res.Err)
+		default:
+			log.Infof("[OK] %s (%s): %d chapters, %d images in %s, sample image %dx%d", res.Source.Name, res.Source.Domain, res.Chapters, res.Images, res.Chapter, res.Width, res.Height)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d sources failed", failed, len(results))
+	}
+	if skipped == len(results) {
+		return fmt.Errorf("no source checked, configure a known series of at least one source")
+	}
+	return nil
+}
+
+func (r Result) fail(step Step, err error) Result {
+	r.Step = step
+	r.Err = err
+	return r
+}
+
+func downloadSample(domain, url string) (int, int, error) {
+	tmp, err := os.CreateTemp("", "doctor-*.img")
+	if err != nil {
+		return 0, 0, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := downloader.DownloadImg(1, url, domain, tmp.Name()); err != nil {
+		return 0, 0, err
+	}
+
+	f, err := os.Open(tmp.Name())
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid image %s: %v", url, err)
+	}
+	return cfg.Width, cfg.Height, nil
+}
@@ -0,0 +1,102 @@
+package doctor
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"comic-crawler/env"
+)
+
+// testSite serves a chapter list linking to one chapter, with its page at
+// /<list>-chap-1.html. Pages of the broken list lack the image selector.
+func testSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewGray(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatal(err)
+	}
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch path := r.URL.Path; {
+		case path == "/img/1.png":
+			w.Write(img.Bytes())
+		case strings.HasSuffix(path, "-chap-1.html") && strings.HasPrefix(path, "/broken"):
+			fmt.Fprint(w, `<html><body><div class="reading"><img src="/img/1.png"></div></body></html>`)
+		case strings.HasSuffix(path, "-chap-1.html"):
+			fmt.Fprintf(w, `<html><body><div class="chapter_content"><img class="lazy" data-src="%s/img/1.png"></div></body></html>`, srv.URL)
+		default:
+			fmt.Fprintf(w, `<html><body><div class="works-chapter-list"><div class="works-chapter-item">
+				<a href="%s%s-chap-1.html">Chương 1</a><div class="time-chap">01/01/2026</div>
+			</div></div></body></html>`, srv.URL, path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	// The crawler and the downloader use the default transport
+	oldTransport := http.DefaultTransport
+	http.DefaultTransport = srv.Client().Transport
+	oldDomain, oldComicId, oldQuery, oldChapter := env.QqtruyenDomain, env.NettruyenCheckComicId, env.QqtruyenCheckChapterQuery, env.QqtruyenCheckChapter
+	t.Cleanup(func() {
+		http.DefaultTransport = oldTransport
+		env.QqtruyenDomain, env.NettruyenCheckComicId, env.QqtruyenCheckChapterQuery, env.QqtruyenCheckChapter = oldDomain, oldComicId, oldQuery, oldChapter
+	})
+	env.QqtruyenDomain = strings.TrimPrefix(srv.URL, "https://")
+	env.NettruyenCheckComicId = 0
+	return srv
+}
+
+func TestCheck(t *testing.T) {
+	srv := testSite(t)
+
+	tests := []struct {
+		query   string
+		chapter string
+		skipped bool
+		step    Step
+	}{
+		{"/comic", "", false, ""},
+		{"/comic", "Chương 1", false, ""},
+		{"/comic", "Chương 2", false, StepChapters},
+		{"/broken", "", false, StepPages},
+		{"", "", true, ""},
+	}
+	for _, tt := range tests {
+		env.QqtruyenCheckChapter = tt.chapter
+		env.QqtruyenCheckChapterQuery = ""
+		if tt.query != "" {
+			env.QqtruyenCheckChapterQuery = srv.URL + tt.query
+		}
+		res := Check(Sources()[1])
+		if res.Skipped != tt.skipped || res.Step != tt.step {
+			t.Errorf("%q %q: skipped %v, step %q, err %v", tt.query, tt.chapter, res.Skipped, res.Step, res.Err)
+		}
+		if tt.query == "/comic" && tt.step == "" && (res.Chapters != 1 || res.Chapter != "Chương 1" || res.Images != 1 || res.Width != 3 || res.Height != 2) {
+			t.Errorf("%q: %+v", tt.query, res)
+		}
+	}
+	if res := Check(Sources()[0]); !res.Skipped {
+		t.Errorf("nettruyen without comic id: %+v", res)
+	}
+}
+
+func TestReport(t *testing.T) {
+	ok := Result{Source: Source{Name: "ok"}}
+	skipped := Result{Source: Source{Name: "skipped"}, Skipped: true}
+	failed := Result{Source: Source{Name: "failed"}, Step: StepPages, Err: fmt.Errorf("no images")}
+
+	if err := Report([]Result{ok, skipped}); err != nil {
+		t.Errorf("ok and skipped: %v", err)
+	}
+	if err := Report([]Result{ok, failed}); err == nil {
+		t.Error("failed source should fail the report")
+	}
+	if err := Report([]Result{skipped, skipped}); err == nil {
+		t.Error("report without any checked source should fail")
+	}
+}
@@ -2,36 +2,47 @@ package epub
 
 import (
 	"fmt"
-	"os"
 	"strings"
 
-	"github.com/anthonynsimon/bild/imgio"
-	"github.com/anthonynsimon/bild/transform"
+	"comic-crawler/service/imaging"
+
 	gub "github.com/go-shiori/go-epub"
 	"github.com/vukyn/kuery/file"
-	"github.com/vukyn/kuery/log"
-	"github.com/vukyn/kuery/query/v2"
 )
 
 type EpubOption struct {
 	Title  string
 	Author string
-	Cover  string
+	Cover  string // image path or data url
 	RTL    bool
+	Layout Layout
+	// Series, identifier, language and other package metadata
+	Metadata Metadata
+	// Kindle panel view, magnifying page quarters in reading order
+	PanelView bool
+	Pipeline  *imaging.Pipeline
+	// Pages detected as ads or duplicates, by file name
+	SkipPages map[string]bool
+	// Directory of templates overriding the embedded ones
+	TemplateDir string
 }
 
 func ImagesToEPUB(folderPath, filePath, fileName string, opt EpubOption) error {
-	// Read all files in folder
-	files, err := os.ReadDir(folderPath)
-	if err != nil {
-		return err
-	}
-
 	// Set default
 	title := opt.Title
 	if title == "" {
 		title = fileName
 	}
+	// Process pages in memory
+	sources, err := imaging.SourcePages(folderPath, opt.SkipPages)
+	if err != nil {
+		return err
+	}
+	opt.Metadata = opt.Metadata.withDefaults(title, sources)
+	if opt.Layout == LayoutFixed || opt.Layout == LayoutKindle {
+		opt.Title = title
+		return imagesToFixedEPUB(sources, folderPath, filePath, fileName, opt)
+	}
 
 	// init EPUB
 	e, err := gub.NewEpub(title)
@@ -39,6 +50,9 @@ func ImagesToEPUB(folderPath, filePath, fileName string, opt EpubOption) error {
 		return err
 	}
 	e.SetAuthor(opt.Author)
+	e.SetIdentifier("urn:uuid:" + opt.Metadata.Identifier)
+	e.SetLang(opt.Metadata.Language)
+	e.SetDescription(opt.Metadata.Description)
 
 	// Set RTL
 	if opt.RTL {
@@ -46,66 +60,54 @@ func ImagesToEPUB(folderPath, filePath, fileName string, opt EpubOption) error {
 	}
 
 	// Load template page
-	comicPage, err := os.ReadFile("service/epub/template/comic_page.html")
+	comicPage, err := readTemplate(opt.TemplateDir, "comic_page.html")
 	if err != nil {
 		return err
 	}
 
 	// Add css to EPUB
-	internalCSS, err := e.AddCSS("service/epub/template/stylesheet.css", "stylesheet.css")
+	stylesheet, err := readTemplate(opt.TemplateDir, "stylesheet.css")
 	if err != nil {
 		return err
 	}
-
-	// Update cover css
-	coverCSS, err := e.AddCSS("service/epub/template/cover.css", "")
+	internalCSS, err := e.AddCSS(dataURL(stylesheet, "text/css"), "stylesheet.css")
 	if err != nil {
 		return err
 	}
 
-	// Add image cover to EPUB
-	coverImg, err := e.AddImage(opt.Cover, "cover.jpg")
-	if err != nil {
-		return err
-	}
-
-	// Add cover to EPUB
-	if err = e.SetCover(coverImg, coverCSS); err != nil {
-		return err
-	}
-
-	for i := range files {
-		info, ok := query.FindFunc(files, func(f os.DirEntry) bool {
-			return f.Name() == fmt.Sprintf("%d.jpg", i+1)
-		})
-		if !ok {
-			log.Warnf("Missing image %d.jpg", i+1)
-			continue
+	if opt.Cover != "" {
+		// Update cover css
+		cover, err := readTemplate(opt.TemplateDir, "cover.css")
+		if err != nil {
+			return err
 		}
-		if info.IsDir() {
-			continue
+		coverCSS, err := e.AddCSS(dataURL(cover, "text/css"), "cover.css")
+		if err != nil {
+			return err
 		}
 
-		imgPath := fmt.Sprintf("%s/%s", folderPath, info.Name())
-
-		// Check if image need to be rotated
-		isRotated := false
-		img, err := imgio.Open(imgPath)
+		// Add image cover to EPUB
+		coverImg, err := e.AddImage(opt.Cover, "cover.jpg")
 		if err != nil {
 			return err
 		}
-		w, h := img.Bounds().Dx(), img.Bounds().Dy()
-		if w > h {
-			isRotated = true
-			img = transform.Rotate(img, -90, &transform.RotationOptions{ResizeBounds: true})
-			imgPath = fmt.Sprintf("%s/%s", folderPath, strings.Split(info.Name(), ".")[0]+"_rotated.jpg")
-			if err := imgio.Save(imgPath, img, imgio.PNGEncoder()); err != nil {
-				return err
-			}
+
+		// Add cover to EPUB
+		if err = e.SetCover(coverImg, coverCSS); err != nil {
+			return err
 		}
+	}
+
+	pages, err := opt.Pipeline.Run(sources)
+	if err != nil {
+		return err
+	}
+
+	for i, page := range pages {
+		part := i + 1
 
 		// Add image to EPUB
-		imgSrc, err := e.AddImage(imgPath, info.Name())
+		imgSrc, err := e.AddImage(page.DataURL(), fmt.Sprintf("%d%s", part, imaging.Extension(page.MediaType())))
 		if err != nil {
 			return err
 		}
@@ -113,12 +115,12 @@ func ImagesToEPUB(folderPath, filePath, fileName string, opt EpubOption) error {
 		// Add section to EPUB
 		htmlPage := string(comicPage)
 		htmlPage = strings.ReplaceAll(htmlPage, "[[img]]", imgSrc)
-		if isRotated {
-			htmlPage = strings.ReplaceAll(htmlPage, "[[rotate]]", "-rotate")
+		if page.Rotated {
+			htmlPage = strings.ReplaceAll(htmlPage, "[[rotate]]", "-rotated")
 		} else {
 			htmlPage = strings.ReplaceAll(htmlPage, "[[rotate]]", "")
 		}
-		if _, err := e.AddSection(htmlPage, fmt.Sprintf("%v - Part %v", opt.Title, i+1), fmt.Sprintf("part%d", i+1), internalCSS); err != nil {
+		if _, err := e.AddSection(htmlPage, fmt.Sprintf("%v - Part %v", opt.Title, part), fmt.Sprintf("part%d", part), internalCSS); err != nil {
 			return err
 		}
 	}
@@ -126,30 +128,13 @@ func ImagesToEPUB(folderPath, filePath, fileName string, opt EpubOption) error {
 	if err := file.CreateFilePath(fmt.Sprintf("%s/epub/", filePath)); err != nil {
 		return err
 	}
-	if err := e.Write(fmt.Sprintf("%s/epub/%s.epub", filePath, fileName)); err != nil {
+	output := fmt.Sprintf("%s/epub/%s.epub", filePath, fileName)
+	if err := e.Write(output); err != nil {
 		return err
 	}
-
-	// Clean up rotated image
-	files, err = os.ReadDir(folderPath)
-	if err != nil {
+	if err := patchPackage(output, opt.TemplateDir, opt.Metadata); err != nil {
 		return err
 	}
-	for _, f := range files {
-		info, err := f.Info()
-		if err != nil {
-			return err // in case of file removed or renamed
-		}
-		if info.IsDir() {
-			continue
-		}
-		if strings.Contains(info.Name(), "_rotated") {
-			if err := os.Remove(fmt.Sprintf("%s/%s", folderPath, info.Name())); err != nil {
-				log.Errorf("Error removing rotated image: %v", err)
-			}
-			continue
-		}
-	}
 
-	return nil
+	return Validate(output)
 }
@@ -0,0 +1,282 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"image"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+
+	"comic-crawler/service/imaging"
+
+	"github.com/vukyn/kuery/file"
+)
+
+// Layout is the kind of EPUB written.
+type Layout string
+
+const (
+	LayoutReflow Layout = "reflow" // generic reflowable book
+	LayoutFixed  Layout = "fixed"  // EPUB 3 fixed layout
+	LayoutKindle Layout = "kindle" // fixed layout with Kindle metadata and panel view
+)
+
+func ParseLayout(layout string) (Layout, error) {
+	switch l := Layout(strings.ToLower(strings.TrimSpace(layout))); l {
+	case "":
+		return LayoutReflow, nil
+	case LayoutReflow, LayoutFixed, LayoutKindle:
+		return l, nil
+	default:
+		return "", fmt.Errorf("unknown epub layout %q, use one of reflow, fixed, kindle", layout)
+	}
+}
+
+// Templates of fixed layout books, by path under template/
+var fixedTemplates = []string{"metadata.opf", "fixed/container.xml", "fixed/content.opf", "fixed/nav.xhtml", "fixed/toc.ncx", "fixed/page.xhtml", "fixed/style.css"}
+
+type fixedBook struct {
+	Id        string
+	Title     string
+	Author    string
+	Metadata  Metadata
+	RTL       bool
+	Kindle    bool
+	PanelView bool
+	// Original resolution, the size of the largest page
+	Width  int
+	Height int
+	Cover  *fixedPage
+	Pages  []fixedPage
+
+	templateDir string
+}
+
+type fixedPage struct {
+	Id        string
+	Title     string
+	Href      string // xhtml path relative to the package
+	Image     string // image path relative to the package
+	MediaType string
+	Width     int
+	Height    int
+	Spread    string // spine properties placing the page in a spread
+	Panels    []panel
+	data      []byte
+}
+
+// panel is a quarter of a page magnified by Kindle panel view.
+type panel struct {
+	Id       string
+	Position string
+	Ordinal  int
+}
+
+// panels returns the page quarters in reading order.
+func panels(rtl bool) []panel {
+	order := []string{"tl", "tr", "bl", "br"}
+	if rtl {
+		order = []string{"tr", "tl", "br", "bl"}
+	}
+	res := make([]panel, 0, len(order))
+	for i, pos := range order {
+		res = append(res, panel{Id: "PV-" + strings.ToUpper(pos), Position: pos, Ordinal: i + 1})
+	}
+	return res
+}
+
+// imagesToFixedEPUB writes a pre-paginated EPUB 3 where every page has the
+// size of its image.
+func imagesToFixedEPUB(sources []string, folderPath, filePath, fileName string, opt EpubOption) error {
+	pages, err := opt.Pipeline.Run(sources)
+	if err != nil {
+		return err
+	}
+	if len(pages) == 0 {
+		return fmt.Errorf("no pages found in %s", folderPath)
+	}
+
+	book := fixedBook{
+		Id:        opt.Metadata.Identifier,
+		Title:     opt.Title,
+		Author:    opt.Author,
+		Metadata:  opt.Metadata,
+		RTL:       opt.RTL,
+		Kindle:    opt.Layout == LayoutKindle,
+		PanelView: opt.PanelView,
+
+		templateDir: opt.TemplateDir,
+	}
+	for i, page := range pages {
+		part := i + 1
+		p := fixedPage{
+			Id:        fmt.Sprintf("page-%04d", part),
+			Title:     fmt.Sprintf("%v - Part %v", opt.Title, part),
+			Href:      fmt.Sprintf("text/page-%04d.xhtml", part),
+			Image:     fmt.Sprintf("images/%04d%s", part, imaging.Extension(page.MediaType())),
+			MediaType: page.MediaType(),
+			Width:     page.Width,
+			Height:    page.Height,
+			data:      page.Data,
+		}
+		if book.Kindle && book.PanelView {
+			p.Panels = panels(opt.RTL)
+		}
+		book.Pages = append(book.Pages, p)
+		if page.Width*page.Height > book.Width*book.Height {
+			book.Width, book.Height = page.Width, page.Height
+		}
+	}
+
+	assignSpreads(book.Pages, opt.RTL)
+
+	if opt.Cover != "" {
+		cover, err := readImage(opt.Cover)
+		if err != nil {
+			return err
+		}
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(cover))
+		if err != nil {
+			return fmt.Errorf("cover %s: %w", opt.Cover, err)
+		}
+		mediaType := http.DetectContentType(cover)
+		book.Cover = &fixedPage{
+			Id:        "cover",
+			Title:     opt.Title,
+			Href:      "text/cover.xhtml",
+			Image:     "images/cover" + imaging.Extension(mediaType),
+			MediaType: mediaType,
+			Width:     cfg.Width,
+			Height:    cfg.Height,
+			Spread:    "rendition:page-spread-center",
+			data:      cover,
+		}
+	}
+
+	if err := file.CreateFilePath(fmt.Sprintf("%s/epub/", filePath)); err != nil {
+		return err
+	}
+	output := fmt.Sprintf("%s/epub/%s.epub", filePath, fileName)
+	if err := book.write(output); err != nil {
+		return err
+	}
+	return Validate(output)
+}
+
+// assignSpreads places pages on alternating sides of spreads, starting on the
+// recto: the right page of left-to-right books, the left page otherwise.
+// Landscape pages fill a spread on their own and the next page starts a new
+// spread.
+func assignSpreads(pages []fixedPage, rtl bool) {
+	recto, verso := "page-spread-right", "page-spread-left"
+	if rtl {
+		recto, verso = verso, recto
+	}
+	side := recto
+	for i := range pages {
+		if pages[i].Width > pages[i].Height {
+			pages[i].Spread = "rendition:page-spread-center"
+			side = recto
+			continue
+		}
+		pages[i].Spread = side
+		if side == recto {
+			side = verso
+		} else {
+			side = recto
+		}
+	}
+}
+
+func (b *fixedBook) write(output string) error {
+	tmpl := template.New("").Funcs(template.FuncMap{
+		"mul": func(a, b int) int { return a * b },
+		"inc": func(i int) int { return i + 1 },
+	})
+	for _, name := range fixedTemplates {
+		data, err := readTemplate(b.templateDir, name)
+		if err != nil {
+			return err
+		}
+		if _, err := tmpl.New(path.Base(name)).Parse(string(data)); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+
+	// The mimetype must come first and uncompressed
+	if err := writeZip(zw, "mimetype", []byte("application/epub+zip"), zip.Store); err != nil {
+		return err
+	}
+
+	render := func(name, path string, data any) error {
+		var buf bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+			return err
+		}
+		return writeZip(zw, path, buf.Bytes(), zip.Deflate)
+	}
+	if err := render("container.xml", "META-INF/container.xml", b); err != nil {
+		return err
+	}
+	if err := render("content.opf", "OEBPS/content.opf", b); err != nil {
+		return err
+	}
+	if err := render("nav.xhtml", "OEBPS/nav.xhtml", b); err != nil {
+		return err
+	}
+	if err := render("toc.ncx", "OEBPS/toc.ncx", b); err != nil {
+		return err
+	}
+	if err := render("style.css", "OEBPS/style.css", b); err != nil {
+		return err
+	}
+
+	pages := b.Pages
+	if b.Cover != nil {
+		pages = append([]fixedPage{*b.Cover}, pages...)
+	}
+	for _, page := range pages {
+		if err := render("page.xhtml", "OEBPS/"+page.Href, page); err != nil {
+			return err
+		}
+		// Images are already compressed
+		if err := writeZip(zw, "OEBPS/"+page.Image, page.data, zip.Store); err != nil {
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func writeZip(zw *zip.Writer, name string, data []byte, method uint16) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// bookId returns a stable uuid for a title, so rebuilt books replace the
+// previous copy on the reader.
+func bookId(title string) string {
+	h := sha1.Sum([]byte(title))
+	h[6] = (h[6] & 0x0f) | 0x50
+	h[8] = (h[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
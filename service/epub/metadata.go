@@ -0,0 +1,134 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Metadata groups the chapters of a series in readers such as Calibre, Kobo
+// and Apple Books.
+type Metadata struct {
+	Identifier  string // stable uuid, see Identifier
+	Series      string
+	SeriesIndex float64 // position of the chapter in the series, 0 if unknown
+	Language    string  // BCP 47 language tag
+	Publisher   string
+	Description string
+	Subjects    []string
+	// Last change of the chapter, the newest page if zero
+	Modified time.Time
+}
+
+// Identifier returns a stable uuid for a chapter of a comic on a source site,
+// so rebuilt books replace the previous copy on the reader.
+func Identifier(source, comic, chapter string) string {
+	return bookId(strings.Join([]string{source, comic, chapter}, "/"))
+}
+
+// Index formats the series index without trailing zeros.
+func (m Metadata) Index() string {
+	return strconv.FormatFloat(m.SeriesIndex, 'f', -1, 64)
+}
+
+// Timestamp formats the modification date for dcterms:modified.
+func (m Metadata) Timestamp() string {
+	return m.Modified.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+// withDefaults fills the fields left empty by the caller.
+func (m Metadata) withDefaults(title string, sources []string) Metadata {
+	if m.Identifier == "" {
+		m.Identifier = bookId(title)
+	}
+	if m.Language == "" {
+		m.Language = "en"
+	}
+	if m.Modified.IsZero() {
+		for _, src := range sources {
+			if info, err := os.Stat(src); err == nil && info.ModTime().After(m.Modified) {
+				m.Modified = info.ModTime()
+			}
+		}
+	}
+	if m.Modified.IsZero() {
+		m.Modified = time.Now()
+	}
+	return m
+}
+
+// renderMetadata renders the metadata elements go-epub has no setter for.
+func renderMetadata(templateDir string, m Metadata) (string, error) {
+	data, err := readTemplate(templateDir, "metadata.opf")
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New("metadata.opf").Parse(string(data))
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, m); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+var modifiedRegex = regexp.MustCompile(`<meta property="dcterms:modified">[^<]*</meta>`)
+
+// patchPackage adds metadata to the package document of an EPUB written by
+// go-epub, which also stamps it with the time of writing.
+func patchPackage(output, templateDir string, m Metadata) error {
+	extra, err := renderMetadata(templateDir, m)
+	if err != nil {
+		return err
+	}
+	modified := fmt.Sprintf(`<meta property="dcterms:modified">%s</meta>`, m.Timestamp())
+	return rewriteZip(output, func(name string, data []byte) []byte {
+		if !strings.HasSuffix(name, ".opf") {
+			return data
+		}
+		opf := modifiedRegex.ReplaceAllLiteralString(string(data), modified)
+		opf = strings.Replace(opf, "</metadata>", extra+"\n  </metadata>", 1)
+		return []byte(opf)
+	})
+}
+
+// rewriteZip rewrites every file of a zip archive through edit, keeping their
+// order and compression.
+func rewriteZip(output string, edit func(name string, data []byte) []byte) error {
+	zr, err := zip.OpenReader(output)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if err := writeZip(zw, f.Name, edit(f.Name, data), f.Method); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	zr.Close()
+	return os.WriteFile(output, buf.Bytes(), 0644)
+}
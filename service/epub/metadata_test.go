@@ -0,0 +1,104 @@
+package epub
+
+import (
+	"archive/zip"
+	"image"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"comic-crawler/service/imaging"
+)
+
+func readPackage(t *testing.T, output string) string {
+	t.Helper()
+	zr, err := zip.OpenReader(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if !strings.HasSuffix(f.Name, ".opf") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	t.Fatal("no package document")
+	return ""
+}
+
+func TestMetadata(t *testing.T) {
+	dir := t.TempDir()
+	chapter := filepath.Join(dir, "Chương 12.5")
+	if err := os.MkdirAll(chapter, 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(chapter, "1.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(f, image.NewGray(image.Rect(0, 0, 60, 90)), nil); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	modified := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(chapter, "1.jpg"), modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	id := Identifier("nettruyen", "42", "Chương 12.5")
+	if id != Identifier("nettruyen", "42", "Chương 12.5") || id == Identifier("nettruyen", "42", "Chương 13") {
+		t.Errorf("identifier %s is not stable per chapter", id)
+	}
+
+	for _, layout := range []Layout{LayoutReflow, LayoutFixed} {
+		err := ImagesToEPUB(chapter, dir, string(layout), EpubOption{
+			Title:    "Series - Chương 12.5",
+			Author:   "Author",
+			Layout:   layout,
+			Pipeline: imaging.NewPipeline(imaging.Config{}),
+			Metadata: Metadata{
+				Identifier:  id,
+				Series:      "Series & Co",
+				SeriesIndex: 12.5,
+				Language:    "vi",
+				Publisher:   "nettruyen",
+				Description: "About the series",
+				Subjects:    []string{"Action", "Comedy"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", layout, err)
+		}
+		opf := readPackage(t, filepath.Join(dir, "epub", string(layout)+".epub"))
+		for _, want := range []string{
+			"urn:uuid:" + id,
+			"<dc:language>vi</dc:language>",
+			"<dc:description>About the series</dc:description>",
+			"<dc:publisher>nettruyen</dc:publisher>",
+			"<dc:subject>Comedy</dc:subject>",
+			`<meta property="belongs-to-collection" id="series">Series &amp; Co</meta>`,
+			`<meta refines="#series" property="group-position">12.5</meta>`,
+			`<meta name="calibre:series" content="Series &amp; Co" />`,
+			`<meta name="calibre:series_index" content="12.5" />`,
+			`<meta property="dcterms:modified">2024-03-01T12:00:00Z</meta>`,
+		} {
+			if !strings.Contains(opf, want) {
+				t.Errorf("%s: missing %s in:\n%s", layout, want, opf)
+			}
+		}
+	}
+}
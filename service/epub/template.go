@@ -0,0 +1,50 @@
+package epub
+
+import (
+	"embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+//go:embed template
+var templates embed.FS
+
+// readTemplate returns a template file by its path under template/, read from
+// dir when it overrides it.
+func readTemplate(dir, name string) ([]byte, error) {
+	if dir != "" {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err == nil {
+			return data, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return templates.ReadFile(path.Join("template", name))
+}
+
+// dataURL returns data as an inline data url.
+func dataURL(data []byte, mediaType string) string {
+	return fmt.Sprintf("data:%s;base64,%s", mediaType, base64.StdEncoding.EncodeToString(data))
+}
+
+// readImage reads an image from a file path or a data url.
+func readImage(src string) ([]byte, error) {
+	if !strings.HasPrefix(src, "data:") {
+		return os.ReadFile(src)
+	}
+	meta, data, ok := strings.Cut(src, ",")
+	if !ok {
+		return nil, errors.New("invalid data url")
+	}
+	if strings.HasSuffix(meta, ";base64") {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	return []byte(data), nil
+}
@@ -0,0 +1,30 @@
+package epub
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadTemplate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "fixed"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "fixed", "style.css"), []byte("body {}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := readTemplate(dir, "fixed/style.css")
+	if err != nil || string(data) != "body {}" {
+		t.Errorf("override: got %q, %v", data, err)
+	}
+	data, err = readTemplate(dir, "comic_page.html")
+	if err != nil || !strings.Contains(string(data), "[[img]]") {
+		t.Errorf("embedded fallback: got %q, %v", data, err)
+	}
+	if _, err := readTemplate("", "missing.html"); err == nil {
+		t.Error("missing template should fail")
+	}
+}
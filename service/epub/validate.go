@@ -0,0 +1,169 @@
+package epub
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const mimetype = "application/epub+zip"
+
+type container struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type packageDoc struct {
+	Version string `xml:"version,attr"`
+	Items   []struct {
+		Id         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+	Itemrefs []struct {
+		Idref string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// Resources referenced from content documents
+var resourceRef = regexp.MustCompile(`(?:src|href|xlink:href)=["']([^"'#]+)`)
+
+// Validate checks the structure of an EPUB: mimetype first and stored,
+// container and package documents present, every manifest item present,
+// spine entries in the manifest and every referenced resource listed.
+func Validate(epubPath string) error {
+	r, err := zip.OpenReader(epubPath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	errs := make([]error, 0)
+	files := make(map[string]*zip.File)
+	for _, f := range r.File {
+		files[f.Name] = f
+	}
+
+	// Mimetype
+	if len(r.File) == 0 || r.File[0].Name != "mimetype" {
+		errs = append(errs, errors.New("mimetype is not the first file"))
+	} else if first := r.File[0]; first.Method != zip.Store || len(first.Extra) > 0 {
+		errs = append(errs, errors.New("mimetype is compressed or has extra fields"))
+	} else if data, err := readZip(first); err != nil || string(data) != mimetype {
+		errs = append(errs, fmt.Errorf("mimetype should be %s", mimetype))
+	}
+
+	// Container
+	var c container
+	if err := decodeZip(files, "META-INF/container.xml", &c); err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	if len(c.Rootfiles) == 0 {
+		return errors.Join(append(errs, errors.New("container.xml has no rootfile"))...)
+	}
+	opfPath := c.Rootfiles[0].FullPath
+	var opf packageDoc
+	if err := decodeZip(files, opfPath, &opf); err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	base := path.Dir(opfPath)
+
+	// Manifest
+	manifest := make(map[string]string)
+	listed := map[string]bool{"mimetype": true, opfPath: true}
+	hasNav := false
+	for _, item := range opf.Items {
+		if _, ok := manifest[item.Id]; ok {
+			errs = append(errs, fmt.Errorf("duplicate manifest id %s", item.Id))
+		}
+		name := path.Join(base, item.Href)
+		manifest[item.Id] = name
+		listed[name] = true
+		if files[name] == nil {
+			errs = append(errs, fmt.Errorf("manifest item %s is missing: %s", item.Id, name))
+		}
+		if strings.Contains(" "+item.Properties+" ", " nav ") {
+			hasNav = true
+		}
+	}
+	if strings.HasPrefix(opf.Version, "3") && !hasNav {
+		errs = append(errs, errors.New("package has no navigation document"))
+	}
+
+	// Spine
+	if len(opf.Itemrefs) == 0 {
+		errs = append(errs, errors.New("spine is empty"))
+	}
+	for _, ref := range opf.Itemrefs {
+		if _, ok := manifest[ref.Idref]; !ok {
+			errs = append(errs, fmt.Errorf("spine item %s is not in the manifest", ref.Idref))
+		}
+	}
+
+	// Resources
+	for name, f := range files {
+		if strings.HasPrefix(name, "META-INF/") || strings.HasSuffix(name, "/") {
+			continue
+		}
+		if !listed[name] {
+			errs = append(errs, fmt.Errorf("%s is not in the manifest", name))
+		}
+		if !isContentDocument(name) {
+			continue
+		}
+		data, err := readZip(f)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, m := range resourceRef.FindAllSubmatch(data, -1) {
+			ref := string(m[1])
+			if strings.Contains(ref, ":") {
+				continue // external link
+			}
+			target := path.Join(path.Dir(name), ref)
+			if files[target] == nil {
+				errs = append(errs, fmt.Errorf("%s references missing resource %s", name, ref))
+			} else if !listed[target] {
+				errs = append(errs, fmt.Errorf("%s references %s which is not in the manifest", name, ref))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func isContentDocument(name string) bool {
+	ext := path.Ext(name)
+	return ext == ".xhtml" || ext == ".html" || ext == ".css" || ext == ".ncx"
+}
+
+func decodeZip(files map[string]*zip.File, name string, v any) error {
+	f := files[name]
+	if f == nil {
+		return fmt.Errorf("%s is missing", name)
+	}
+	data, err := readZip(f)
+	if err != nil {
+		return err
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+func readZip(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
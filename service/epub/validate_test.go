@@ -0,0 +1,135 @@
+package epub
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testBook(rtl bool) *fixedBook {
+	book := &fixedBook{
+		Id:     bookId("Test"),
+		Title:  "Test & <Co>",
+		Author: "Unknown",
+		Metadata: Metadata{
+			Language: "en",
+			Modified: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		RTL: rtl,
+	}
+	for i, size := range [][2]int{{100, 150}, {100, 150}, {200, 150}, {100, 150}} {
+		id := fmt.Sprintf("page-%04d", i+1)
+		book.Pages = append(book.Pages, fixedPage{
+			Id:        id,
+			Title:     id,
+			Href:      "text/" + id + ".xhtml",
+			Image:     "images/" + id + ".jpg",
+			MediaType: "image/jpeg",
+			Width:     size[0],
+			Height:    size[1],
+			data:      []byte{0xff, 0xd8, 0xff},
+		})
+	}
+	assignSpreads(book.Pages, rtl)
+	return book
+}
+
+func TestAssignSpreads(t *testing.T) {
+	tests := []struct {
+		rtl  bool
+		want []string
+	}{
+		{false, []string{"page-spread-right", "page-spread-left", "rendition:page-spread-center", "page-spread-right"}},
+		{true, []string{"page-spread-left", "page-spread-right", "rendition:page-spread-center", "page-spread-left"}},
+	}
+	for _, tt := range tests {
+		for i, page := range testBook(tt.rtl).Pages {
+			if page.Spread != tt.want[i] {
+				t.Errorf("rtl=%v page %d: got %s, want %s", tt.rtl, i+1, page.Spread, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestValidateFixed(t *testing.T) {
+	for _, kindle := range []bool{false, true} {
+		book := testBook(true)
+		book.Kindle, book.PanelView = kindle, kindle
+		if kindle {
+			for i := range book.Pages {
+				book.Pages[i].Panels = panels(true)
+			}
+		}
+		output := filepath.Join(t.TempDir(), "book.epub")
+		if err := book.write(output); err != nil {
+			t.Fatal(err)
+		}
+		if err := Validate(output); err != nil {
+			t.Errorf("kindle=%v: %v", kindle, err)
+		}
+	}
+}
+
+func writeTestZip(t *testing.T, files [][2]string) string {
+	t.Helper()
+	output := filepath.Join(t.TempDir(), "book.epub")
+	f, err := os.Create(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for _, file := range files {
+		method := zip.Deflate
+		if file[0] == "mimetype" {
+			method = zip.Store
+		}
+		if err := writeZip(zw, file[0], []byte(file[1]), method); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return output
+}
+
+func TestValidateErrors(t *testing.T) {
+	containerXML := `<container><rootfiles><rootfile full-path="OEBPS/content.opf"/></rootfiles></container>`
+	opf := `<package version="3.0">
+	<manifest>
+		<item id="nav" href="nav.xhtml" properties="nav"/>
+		<item id="p1" href="p1.xhtml"/>
+		<item id="img" href="missing.jpg"/>
+	</manifest>
+	<spine><itemref idref="p1"/><itemref idref="p2"/></spine>
+</package>`
+
+	output := writeTestZip(t, [][2]string{
+		{"META-INF/container.xml", containerXML},
+		{"mimetype", mimetype},
+		{"OEBPS/content.opf", opf},
+		{"OEBPS/nav.xhtml", `<a href="p1.xhtml">1</a>`},
+		{"OEBPS/p1.xhtml", `<img src="other.jpg"/>`},
+		{"OEBPS/extra.css", ``},
+	})
+	err := Validate(output)
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{
+		"mimetype is not the first file",
+		"manifest item img is missing",
+		"spine item p2 is not in the manifest",
+		"OEBPS/extra.css is not in the manifest",
+		"references missing resource other.jpg",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing error %q in:\n%v", want, err)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"comic-crawler/service/cover"
+	"comic-crawler/service/crawler"
+	"comic-crawler/service/library"
+	"comic-crawler/service/pathname"
+)
+
+// SeriesFile is the Mylar series metadata read by Komga and Kavita.
+const SeriesFile = "series.json"
+
+// Link is how converted chapters are placed into the media library.
+type Link string
+
+const (
+	Copy     Link = "copy"     // independent copy
+	Symlink  Link = "symlink"  // symbolic link to the converted file
+	Hardlink Link = "hardlink" // hard link, same file system only
+)
+
+func ParseLink(link string) (Link, error) {
+	switch l := Link(strings.ToLower(strings.TrimSpace(link))); l {
+	case "":
+		return Copy, nil
+	case Copy, Symlink, Hardlink:
+		return l, nil
+	default:
+		return "", fmt.Errorf("unknown library link %q, use one of copy, symlink, hardlink", link)
+	}
+}
+
+type Option struct {
+	Library string // media library folder, series are exported in <Library>/<Series>
+	Series  string // series name
+	ComicId string
+	// Series metadata written to series.json
+	Publisher   string
+	Description string
+	// Formats exported by name (cbz, epub, pdf), a server shows each as a book
+	Formats []string
+	Link    Link
+}
+
+// Export places the converted chapters of a comic in a media library as
+// <Series>/<Series> - Ch. 012.cbz with the series metadata and cover.
+// Files already in place are kept, so exporting again only adds the new
+// chapters. Chapters whose file names collide get a (2), (3)... suffix in
+// reading order. It returns the files placed.
+func Export(comicPath string, opt Option) ([]string, error) {
+	series := pathname.Clean(opt.Series)
+	dir := filepath.Join(opt.Library, series)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	books, err := library.Books(comicPath)
+	if err != nil {
+		return nil, err
+	}
+
+	placed := make([]string, 0)
+	year := 0
+	issues := 0
+	// Chapters by file name, different chapters may parse to the same
+	// number, such as "Chapter 12" and "Chapter 12: Extra"
+	chapters := make(map[string]string)
+	for _, book := range books {
+		name := ChapterFile(series, book.Chapter)
+		for i := 2; chapters[strings.ToLower(name)] != ""; i++ {
+			name = fmt.Sprintf("%s (%d)", ChapterFile(series, book.Chapter), i)
+		}
+		exported := false
+		for _, f := range book.Files {
+			if !slices.Contains(opt.Formats, f.Format.Name) {
+				continue
+			}
+			exported = true
+			if y := f.Modified.Year(); year == 0 || y < year {
+				year = y
+			}
+			dest := filepath.Join(dir, name+"."+f.Format.Name)
+			ok, err := place(f.Path, dest, opt.Link)
+			if err != nil {
+				return placed, fmt.Errorf("%s: %w", book.Chapter, err)
+			}
+			if ok {
+				placed = append(placed, dest)
+			}
+		}
+		if exported {
+			chapters[strings.ToLower(name)] = book.Chapter
+			issues++
+		}
+	}
+
+	art := filepath.Join(comicPath, cover.SeriesFile)
+	if _, err := os.Stat(art); err == nil {
+		dest := filepath.Join(dir, cover.SeriesFile)
+		if ok, err := place(art, dest, opt.Link); err != nil {
+			return placed, err
+		} else if ok {
+			placed = append(placed, dest)
+		}
+	}
+
+	metadata := seriesMetadata{
+		Type:            "comicSeries",
+		Publisher:       opt.Publisher,
+		Name:            opt.Series,
+		ComicId:         opt.ComicId,
+		Year:            year,
+		DescriptionText: opt.Description,
+		BookType:        "Print",
+		ComicImage:      "",
+		TotalIssues:     issues,
+		Status:          "Continuing",
+	}
+	data, err := json.MarshalIndent(map[string]any{"version": "1.0.2", "metadata": metadata}, "", "  ")
+	if err != nil {
+		return placed, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, SeriesFile), data, 0644); err != nil {
+		return placed, err
+	}
+	return placed, nil
+}
+
+// seriesMetadata is the Mylar series.json metadata, the fields Komga requires.
+type seriesMetadata struct {
+	Type            string `json:"type"`
+	Publisher       string `json:"publisher"`
+	Name            string `json:"name"`
+	ComicId         string `json:"comicid"`
+	Year            int    `json:"year"`
+	DescriptionText string `json:"description_text"`
+	BookType        string `json:"booktype"`
+	ComicImage      string `json:"ComicImage"`
+	TotalIssues     int    `json:"total_issues"`
+	PublicationRun  string `json:"publication_run"`
+	Status          string `json:"status"`
+}
+
+// ChapterFile returns the file name, without extension, media servers parse
+// the volume and chapter number from: "Series - Vol. 02 Ch. 012.5".
+// Chapters without a number keep their name.
+func ChapterFile(series, chapter string) string {
+	number, ok, volume, _, _ := crawler.ParseChapterName(chapter)
+	if !ok {
+		return pathname.Clean(series + " - " + chapter)
+	}
+	// Pad the integer part so files sort by name
+	whole, frac, _ := strings.Cut(strconv.FormatFloat(number, 'f', -1, 64), ".")
+	n := fmt.Sprintf("%03s", whole)
+	if frac != "" {
+		n += "." + frac
+	}
+	if volume > 0 {
+		return pathname.Clean(fmt.Sprintf("%s - Vol. %02d Ch. %s", series, volume, n))
+	}
+	return pathname.Clean(fmt.Sprintf("%s - Ch. %s", series, n))
+}
+
+// place puts src at dest unless dest already is src, a link to it or an
+// unchanged copy. It reports whether dest was written.
+func place(src, dest string, link Link) (bool, error) {
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return false, err
+	}
+	if destInfo, err := os.Lstat(dest); err == nil {
+		switch {
+		case link == Symlink && destInfo.Mode()&os.ModeSymlink != 0:
+			if target, err := os.Readlink(dest); err == nil && target == absolute(src) {
+				return false, nil
+			}
+		case link == Hardlink && os.SameFile(srcInfo, destInfo):
+			return false, nil
+		case link == Copy && destInfo.Mode().IsRegular() &&
+			destInfo.Size() == srcInfo.Size() && destInfo.ModTime().Equal(srcInfo.ModTime()):
+			return false, nil
+		}
+		if err := os.Remove(dest); err != nil {
+			return false, err
+		}
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	switch link {
+	case Symlink:
+		err = os.Symlink(absolute(src), dest)
+	case Hardlink:
+		err = os.Link(src, dest)
+	default:
+		err = copyFile(src, dest, srcInfo)
+	}
+	return err == nil, err
+}
+
+func absolute(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// copyFile copies src keeping its modification time, which tells unchanged
+// copies apart.
+func copyFile(src, dest string, info os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := dest + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chtimes(tmp, info.ModTime(), info.ModTime()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
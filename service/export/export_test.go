@@ -0,0 +1,105 @@
+package export
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChapterFile(t *testing.T) {
+	tests := []struct {
+		chapter string
+		want    string
+	}{
+		{"Chapter 12", "Series - Ch. 012"},
+		{"Chương 12.5", "Series - Ch. 012.5"},
+		{"Chapter 1234", "Series - Ch. 1234"},
+		{"Vol.2 Chapter 7", "Series - Vol. 02 Ch. 007"},
+		{"Oneshot: Prologue?", "Series - Oneshot_ Prologue_"},
+	}
+	for _, tt := range tests {
+		if got := ChapterFile("Series", tt.chapter); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.chapter, got, tt.want)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	comic := t.TempDir()
+	writeFile(t, filepath.Join(comic, "cbz", "Chapter 1.cbz"), "one")
+	writeFile(t, filepath.Join(comic, "cbz", "Chapter 2.cbz"), "two")
+	writeFile(t, filepath.Join(comic, "cbz", "Chapter 2_ Extra.cbz"), "extra") // same number as chapter 2
+	writeFile(t, filepath.Join(comic, "epub", "Chapter 1.epub"), "epub")
+	writeFile(t, filepath.Join(comic, "epub", "Chapter 3.epub"), "epub") // not exported
+	writeFile(t, filepath.Join(comic, "cover.jpg"), "cover")
+
+	for _, link := range []Link{Copy, Symlink, Hardlink} {
+		lib := t.TempDir()
+		opt := Option{Library: lib, Series: "Re:Zero", ComicId: "42", Publisher: "nettruyen", Formats: []string{"cbz"}, Link: link}
+		placed, err := Export(comic, opt)
+		if err != nil {
+			t.Fatalf("%s: %v", link, err)
+		}
+		if len(placed) != 4 {
+			t.Errorf("%s: placed %v", link, placed)
+		}
+		data, err := os.ReadFile(filepath.Join(lib, "Re_Zero", "Re_Zero - Ch. 002.cbz"))
+		if err != nil || string(data) != "two" {
+			t.Errorf("%s: chapter 2 is %q, %v", link, data, err)
+		}
+		data, err = os.ReadFile(filepath.Join(lib, "Re_Zero", "Re_Zero - Ch. 002 (2).cbz"))
+		if err != nil || string(data) != "extra" {
+			t.Errorf("%s: chapter 2 extra is %q, %v", link, data, err)
+		}
+		if _, err := os.Stat(filepath.Join(lib, "Re_Zero", "Re_Zero - Ch. 001.epub")); !os.IsNotExist(err) {
+			t.Errorf("%s: epub should not be exported", link)
+		}
+		if link == Symlink {
+			if info, err := os.Lstat(filepath.Join(lib, "Re_Zero", "cover.jpg")); err != nil || info.Mode()&os.ModeSymlink == 0 {
+				t.Errorf("cover is not a symlink: %v", err)
+			}
+		}
+
+		var series struct {
+			Metadata seriesMetadata `json:"metadata"`
+		}
+		data, err = os.ReadFile(filepath.Join(lib, "Re_Zero", SeriesFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(data, &series); err != nil {
+			t.Fatal(err)
+		}
+		if series.Metadata.Name != "Re:Zero" || series.Metadata.TotalIssues != 3 || series.Metadata.Year == 0 {
+			t.Errorf("%s: series %+v", link, series.Metadata)
+		}
+
+		// Exporting again keeps the files in place
+		if placed, err := Export(comic, opt); err != nil || len(placed) != 0 {
+			t.Errorf("%s: second export placed %v, %v", link, placed, err)
+		}
+	}
+}
+
+func TestParseLink(t *testing.T) {
+	if l, err := ParseLink(""); err != nil || l != Copy {
+		t.Errorf("empty link: got %s, %v", l, err)
+	}
+	if l, err := ParseLink("HardLink"); err != nil || l != Hardlink {
+		t.Errorf("hardlink: got %s, %v", l, err)
+	}
+	if _, err := ParseLink("move"); err == nil {
+		t.Error("unknown link should fail")
+	}
+}
@@ -0,0 +1,91 @@
+package imaging
+
+import (
+	"image"
+	"math"
+
+	"github.com/anthonynsimon/bild/clone"
+	"github.com/anthonynsimon/bild/transform"
+)
+
+type CropOption struct {
+	Auto            bool    // trim uniform borders
+	Tolerance       float64 // max luminance difference from the border color
+	Limit           float64 // max fraction of the width or height trimmed on each side
+	WatermarkTop    int     // band in px removed from the top before trimming
+	WatermarkBottom int     // band in px removed from the bottom before trimming
+}
+
+// Fraction of pixels in a border line allowed to differ, to ignore scan noise
+const cropNoise = 0.005
+
+// Enabled reports whether images need to be cropped.
+func (o CropOption) Enabled() bool {
+	return o.Auto || o.WatermarkTop > 0 || o.WatermarkBottom > 0
+}
+
+// AutoCrop removes watermark bands then trims uniform borders of any color,
+// never trimming more than the limit on each side.
+func AutoCrop(img image.Image, opt CropOption) image.Image {
+	b := img.Bounds()
+	rect := b
+	if opt.WatermarkTop+opt.WatermarkBottom < b.Dy() {
+		rect.Min.Y += opt.WatermarkTop
+		rect.Max.Y -= opt.WatermarkBottom
+	}
+
+	if opt.Auto {
+		src := clone.AsShallowRGBA(img)
+		maxX := int(float64(rect.Dx()) * opt.Limit)
+		maxY := int(float64(rect.Dy()) * opt.Limit)
+		row := func(y int) func(int) float64 { return func(x int) float64 { return luminance(src, x, y) } }
+		col := func(x int) func(int) float64 { return func(y int) float64 { return luminance(src, x, y) } }
+
+		top := trimLines(maxY, opt.Tolerance, func(i int) (func(int) float64, int, int) {
+			return row(rect.Min.Y + i), rect.Min.X, rect.Max.X
+		})
+		bottom := trimLines(maxY, opt.Tolerance, func(i int) (func(int) float64, int, int) {
+			return row(rect.Max.Y - 1 - i), rect.Min.X, rect.Max.X
+		})
+		left := trimLines(maxX, opt.Tolerance, func(i int) (func(int) float64, int, int) {
+			return col(rect.Min.X + i), rect.Min.Y, rect.Max.Y
+		})
+		right := trimLines(maxX, opt.Tolerance, func(i int) (func(int) float64, int, int) {
+			return col(rect.Max.X - 1 - i), rect.Min.Y, rect.Max.Y
+		})
+		rect = image.Rect(rect.Min.X+left, rect.Min.Y+top, rect.Max.X-right, rect.Max.Y-bottom)
+	}
+
+	if rect == b || rect.Empty() {
+		return img
+	}
+	return transform.Crop(img, rect)
+}
+
+// trimLines counts the lines from an edge, up to limit, which are uniform and
+// close to the color of the outermost line.
+func trimLines(limit int, tolerance float64, line func(i int) (px func(int) float64, from, to int)) int {
+	ref := -1.0
+	for i := 0; i < limit; i++ {
+		px, from, to := line(i)
+		sum := 0.0
+		for p := from; p < to; p++ {
+			sum += px(p)
+		}
+		mean := sum / float64(to-from)
+		if ref < 0 {
+			ref = mean
+		}
+
+		outliers := 0
+		for p := from; p < to; p++ {
+			if math.Abs(px(p)-ref) > tolerance {
+				outliers++
+			}
+		}
+		if float64(outliers) > float64(to-from)*cropNoise {
+			return i
+		}
+	}
+	return limit
+}
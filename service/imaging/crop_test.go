@@ -0,0 +1,34 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestAutoCrop(t *testing.T) {
+	// White page with busy content inside a 10px border
+	img := image.NewRGBA(image.Rect(0, 0, 100, 200))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	for y := 10; y < 190; y++ {
+		for x := 10; x < 90; x++ {
+			img.Set(x, y, color.Gray{Y: uint8((x * y) % 200)})
+		}
+	}
+
+	tests := []struct {
+		name string
+		opt  CropOption
+		want image.Rectangle
+	}{
+		{"borders", CropOption{Auto: true, Tolerance: 16, Limit: 0.2}, image.Rect(10, 10, 90, 190)},
+		{"limit", CropOption{Auto: true, Tolerance: 16, Limit: 0.02}, image.Rect(2, 4, 98, 196)},
+		{"watermark", CropOption{WatermarkTop: 5, WatermarkBottom: 20}, image.Rect(0, 5, 100, 180)},
+	}
+	for _, tt := range tests {
+		if got := AutoCrop(img, tt.opt).Bounds(); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
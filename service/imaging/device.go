@@ -0,0 +1,104 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/anthonynsimon/bild/adjust"
+	"github.com/anthonynsimon/bild/transform"
+)
+
+// Profile is the panel of an e-reader or tablet.
+type Profile struct {
+	Name   string
+	Width  int
+	Height int
+	EInk   bool // grayscale panel
+}
+
+var Profiles = map[string]Profile{
+	"kindle-paperwhite": {Name: "kindle-paperwhite", Width: 1236, Height: 1648, EInk: true},
+	"kindle-oasis":      {Name: "kindle-oasis", Width: 1264, Height: 1680, EInk: true},
+	"kindle-scribe":     {Name: "kindle-scribe", Width: 1860, Height: 2480, EInk: true},
+	"kobo-clara":        {Name: "kobo-clara", Width: 1072, Height: 1448, EInk: true},
+	"kobo-libra":        {Name: "kobo-libra", Width: 1264, Height: 1680, EInk: true},
+	"tablet":            {Name: "tablet", Width: 1536, Height: 2048, EInk: false},
+}
+
+type DeviceOption struct {
+	Profile   Profile
+	Grayscale bool
+	Gamma     float64 // 1 keeps the original gamma
+	Contrast  float64 // percent change from -100 to 100, 0 keeps the original contrast
+	Quality   int     // JPEG quality used to re-encode
+}
+
+// ParseProfile returns a known profile by name, or a custom profile given as WxH.
+func ParseProfile(name string) (Profile, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if profile, ok := Profiles[name]; ok {
+		return profile, nil
+	}
+	var w, h int
+	if _, err := fmt.Sscanf(name, "%dx%d", &w, &h); err != nil || w <= 0 || h <= 0 {
+		names := make([]string, 0, len(Profiles))
+		for n := range Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return Profile{}, fmt.Errorf("unknown device profile %q, use one of %s or WxH", name, strings.Join(names, ", "))
+	}
+	return Profile{Name: name, Width: w, Height: h}, nil
+}
+
+// ParseGrayscale parses a grayscale setting: AUTO converts for e-ink
+// profiles only, otherwise a boolean.
+func ParseGrayscale(value string, profile Profile) (bool, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.EqualFold(value, "auto") {
+		return profile.EInk, nil
+	}
+	grayscale, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid grayscale %q, use one of AUTO, TRUE, FALSE", value)
+	}
+	return grayscale, nil
+}
+
+// Enabled reports whether images need to be processed for a device.
+func (o DeviceOption) Enabled() bool {
+	return o.Profile.Width > 0 || o.Grayscale || (o.Gamma > 0 && o.Gamma != 1) || o.Contrast != 0
+}
+
+// Fit downscales an image to fit within the panel, keeping its aspect ratio.
+// Images already smaller than the panel are kept as is.
+func Fit(img image.Image, profile Profile) image.Image {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if profile.Width <= 0 || profile.Height <= 0 || (w <= profile.Width && h <= profile.Height) {
+		return img
+	}
+	scale := min(float64(profile.Width)/float64(w), float64(profile.Height)/float64(h))
+	return transform.Resize(img, max(int(float64(w)*scale), 1), max(int(float64(h)*scale), 1), transform.Lanczos)
+}
+
+// ApplyDevice resizes an image to the device panel and applies the color
+// corrections, converting to grayscale last.
+func ApplyDevice(img image.Image, opt DeviceOption) image.Image {
+	img = Fit(img, opt.Profile)
+	if opt.Gamma > 0 && opt.Gamma != 1 {
+		img = adjust.Gamma(img, opt.Gamma)
+	}
+	if opt.Contrast != 0 {
+		img = adjust.Contrast(img, opt.Contrast/100)
+	}
+	if opt.Grayscale {
+		gray := image.NewGray(img.Bounds())
+		draw.Draw(gray, gray.Bounds(), img, img.Bounds().Min, draw.Src)
+		img = gray
+	}
+	return img
+}
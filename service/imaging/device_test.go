@@ -0,0 +1,99 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestParseProfile(t *testing.T) {
+	tests := []struct {
+		name string
+		want Profile
+		err  bool
+	}{
+		{"kobo-clara", Profiles["kobo-clara"], false},
+		{" Kindle-Scribe ", Profiles["kindle-scribe"], false},
+		{"800x600", Profile{Name: "800x600", Width: 800, Height: 600}, false},
+		{"0x600", Profile{}, true},
+		{"kindle", Profile{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseProfile(tt.name)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("%q: got %+v, %v", tt.name, got, err)
+		}
+	}
+}
+
+func TestParseGrayscale(t *testing.T) {
+	eink, tablet := Profiles["kobo-clara"], Profiles["tablet"]
+	tests := []struct {
+		value   string
+		profile Profile
+		want    bool
+		err     bool
+	}{
+		{"AUTO", eink, true, false},
+		{"auto", tablet, false, false},
+		{"", eink, true, false},
+		{"TRUE", tablet, true, false},
+		{"false", eink, false, false},
+		{"grey", eink, false, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseGrayscale(tt.value, tt.profile)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("%q on %s: got %v, %v", tt.value, tt.profile.Name, got, err)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	profile := Profile{Width: 100, Height: 150}
+	tests := []struct {
+		name    string
+		size    image.Point
+		profile Profile
+		want    image.Point
+	}{
+		{"taller", image.Pt(200, 600), profile, image.Pt(50, 150)},
+		{"wider", image.Pt(400, 300), profile, image.Pt(100, 75)},
+		{"smaller", image.Pt(80, 100), profile, image.Pt(80, 100)},
+		{"no profile", image.Pt(400, 300), Profile{}, image.Pt(400, 300)},
+	}
+	for _, tt := range tests {
+		img := image.NewRGBA(image.Rectangle{Max: tt.size})
+		if got := Fit(img, tt.profile).Bounds().Size(); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyDevice(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 60))
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 40; x++ {
+			img.Set(x, y, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+
+	if got := ApplyDevice(img, DeviceOption{}); got != image.Image(img) {
+		t.Error("disabled options should keep the image")
+	}
+
+	got := ApplyDevice(img, DeviceOption{Profile: Profile{Width: 20, Height: 30}, Grayscale: true})
+	gray, ok := got.(*image.Gray)
+	if !ok {
+		t.Fatalf("grayscale: got %T", got)
+	}
+	if size := gray.Bounds().Size(); size != image.Pt(20, 30) {
+		t.Errorf("size: got %v", size)
+	}
+
+	before := color.GrayModel.Convert(img.At(0, 0)).(color.Gray).Y
+	brighter := ApplyDevice(img, DeviceOption{Gamma: 1.8, Grayscale: true}).(*image.Gray).GrayAt(0, 0).Y
+	if brighter <= before {
+		t.Errorf("gamma 1.8: got %d, want brighter than %d", brighter, before)
+	}
+}
@@ -0,0 +1,121 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/anthonynsimon/bild/clone"
+)
+
+// Encoding is the image format of output pages.
+type Encoding string
+
+const (
+	EncodeOriginal Encoding = "original" // keep source files, modified pages as JPEG
+	EncodeJPEG     Encoding = "jpeg"     // recompress every page as JPEG
+	EncodePNG      Encoding = "png"      // lossless, suited for line art
+	// Lossless WebP, ignores the quality: smaller than PNG for line art but
+	// often larger than the JPEG source for photos and screentones
+	EncodeWebP Encoding = "webp"
+)
+
+func ParseEncoding(encoding string) (Encoding, error) {
+	switch e := Encoding(strings.ToLower(strings.TrimSpace(encoding))); e {
+	case "":
+		return EncodeOriginal, nil
+	case "jpg":
+		return EncodeJPEG, nil
+	case EncodeOriginal, EncodeJPEG, EncodePNG, EncodeWebP:
+		return e, nil
+	case "avif", "jxl", "jpegxl":
+		return "", fmt.Errorf("%s encoding is not supported, use one of original, jpeg, png, webp", encoding)
+	default:
+		return "", fmt.Errorf("unknown encoding %q, use one of original, jpeg, png, webp", encoding)
+	}
+}
+
+// Encode encodes an image, storing grayscale images with a single channel
+// where the format allows it. Quality only applies to JPEG.
+func Encode(img image.Image, encoding Encoding, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch encoding {
+	case EncodePNG:
+		enc := png.Encoder{CompressionLevel: png.BestCompression}
+		err = enc.Encode(&buf, toGray(img))
+	case EncodeWebP:
+		err = nativewebp.Encode(&buf, img, nil)
+	default:
+		err = jpeg.Encode(&buf, toGray(img), &jpeg.Options{Quality: quality})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Extension returns the file extension of an encoded image.
+func Extension(mediaType string) string {
+	switch mediaType {
+	case "image/png":
+		return ".png"
+	case "image/webp":
+		return ".webp"
+	case "image/gif":
+		return ".gif"
+	default:
+		return ".jpg"
+	}
+}
+
+// toGray converts an image without color to a single channel image.
+func toGray(img image.Image) image.Image {
+	if _, ok := img.(*image.Gray); ok {
+		return img
+	}
+	src := clone.AsShallowRGBA(img)
+	for i := 0; i+3 < len(src.Pix); i += 4 {
+		if p := src.Pix[i : i+4 : i+4]; p[0] != p[1] || p[1] != p[2] || p[3] != 0xff {
+			return img
+		}
+	}
+	gray := image.NewGray(src.Bounds())
+	draw.Draw(gray, gray.Bounds(), src, src.Bounds().Min, draw.Src)
+	return gray
+}
+
+// SizeReport compares the size of source images with the encoded pages.
+type SizeReport struct {
+	Pages  int
+	Source int64
+	Output int64
+}
+
+func (r SizeReport) String() string {
+	change := "0.0% saved"
+	if r.Source > 0 && r.Output > r.Source {
+		change = fmt.Sprintf("%.1f%% larger", 100*float64(r.Output-r.Source)/float64(r.Source))
+	} else if r.Source > 0 {
+		change = fmt.Sprintf("%.1f%% saved", 100*float64(r.Source-r.Output)/float64(r.Source))
+	}
+	return fmt.Sprintf("%d pages, %s → %s (%s)", r.Pages, formatSize(r.Source), formatSize(r.Output), change)
+}
+
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,155 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func lineArt() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			c := color.RGBA{255, 255, 255, 255}
+			if x%8 == 0 || y == x/2 {
+				c = color.RGBA{0, 0, 0, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestEncode(t *testing.T) {
+	img := lineArt()
+	tests := []struct {
+		encoding Encoding
+		want     string
+	}{
+		{EncodeOriginal, "image/jpeg"},
+		{EncodeJPEG, "image/jpeg"},
+		{EncodePNG, "image/png"},
+		{EncodeWebP, "image/webp"},
+	}
+	for _, tt := range tests {
+		data, err := Encode(img, tt.encoding, 85)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.encoding, err)
+		}
+		if got := http.DetectContentType(data); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.encoding, got, tt.want)
+		}
+	}
+
+	// Lossless encodings keep every pixel
+	data, err := Encode(img, EncodeWebP, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := webp.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []image.Point{{0, 0}, {1, 0}, {10, 5}, {8, 40}} {
+		r1, g1, b1, _ := img.At(p.X, p.Y).RGBA()
+		r2, g2, b2, _ := decoded.At(p.X, p.Y).RGBA()
+		if r1 != r2 || g1 != g2 || b1 != b2 {
+			t.Errorf("webp pixel %v: got %v, want %v", p, decoded.At(p.X, p.Y), img.At(p.X, p.Y))
+		}
+	}
+
+	data, err = Encode(img, EncodePNG, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err = png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := decoded.(*image.Gray); !ok {
+		t.Errorf("png of a grayscale page should have a single channel, got %T", decoded)
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Encoding
+		wantErr bool
+	}{
+		{"", EncodeOriginal, false},
+		{"JPG", EncodeJPEG, false},
+		{" webp ", EncodeWebP, false},
+		{"avif", "", true},
+		{"bmp", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseEncoding(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseEncoding(%q) = %q, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestSizeReport(t *testing.T) {
+	r := SizeReport{Pages: 3, Source: 4 << 20, Output: 1 << 20}
+	if got, want := r.String(), "3 pages, 4.0 MB → 1.0 MB (75.0% saved)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWebPReport(t *testing.T) {
+	// Noise stands for photos and screentones, which lossless WebP grows
+	r := rand.New(rand.NewSource(1))
+	noisy := image.NewRGBA(image.Rect(0, 0, 200, 300))
+	for i := range noisy.Pix {
+		noisy.Pix[i] = uint8(r.Intn(256))
+	}
+	flat := image.NewGray(image.Rect(0, 0, 200, 300))
+	for i := range flat.Pix {
+		flat.Pix[i] = 255
+	}
+
+	dir := t.TempDir()
+	for name, img := range map[string]image.Image{"noisy.jpg": noisy, "flat.jpg": flat} {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := jpeg.Encode(f, img, &jpeg.Options{Quality: 75}); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+
+	report := func(name string, quality int) SizeReport {
+		p := NewPipeline(Config{Encoding: EncodeWebP, Quality: quality, Workers: 1})
+		if _, err := p.Run([]string{filepath.Join(dir, name)}); err != nil {
+			t.Fatal(err)
+		}
+		return p.Report()
+	}
+
+	// Lossless output larger than the source keeps the source
+	if got := report("noisy.jpg", 85); got.Output != got.Source {
+		t.Errorf("noisy page: %s, want the source kept", got)
+	}
+	got := report("flat.jpg", 85)
+	if got.Output >= got.Source || !strings.Contains(got.String(), "saved") {
+		t.Errorf("flat page: %s, want it smaller", got)
+	}
+	// Quality does not change lossless output
+	if low := report("flat.jpg", 10); low != got {
+		t.Errorf("quality 10: %s, want %s", low, got)
+	}
+}
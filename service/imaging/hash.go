@@ -0,0 +1,45 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+	"strconv"
+
+	"github.com/anthonynsimon/bild/transform"
+)
+
+// Hash returns the 64 bit difference hash (dHash) of an image: similar
+// images have hashes a small Hamming distance apart, regardless of size
+// and compression.
+func Hash(img image.Image) uint64 {
+	small := transform.Resize(img, 9, 8, transform.Box)
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if luminance(small, x, y) > luminance(small, x+1, y) {
+				hash |= 1 << uint(y*8+x)
+			}
+		}
+	}
+	return hash
+}
+
+// Distance returns the Hamming distance between two hashes.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+func FormatHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+func ParseHash(s string) (uint64, error) {
+	return strconv.ParseUint(s, 16, 64)
+}
+
+func luminance(img *image.RGBA, x, y int) float64 {
+	i := img.PixOffset(x, y)
+	p := img.Pix[i : i+3 : i+3]
+	return 0.299*float64(p[0]) + 0.587*float64(p[1]) + 0.114*float64(p[2])
+}
@@ -0,0 +1,31 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/anthonynsimon/bild/transform"
+)
+
+func TestHash(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 320, 480))
+	other := image.NewRGBA(image.Rect(0, 0, 320, 480))
+	for y := 0; y < 480; y++ {
+		for x := 0; x < 320; x++ {
+			img.Set(x, y, color.Gray{Y: uint8((x + y) % 256)})
+			other.Set(x, y, color.Gray{Y: uint8((x * y / 7) % 256)})
+		}
+	}
+
+	hash := Hash(img)
+	if d := Distance(hash, Hash(transform.Resize(img, 160, 240, transform.Linear))); d > 4 {
+		t.Errorf("resized copy distance %d, want <= 4", d)
+	}
+	if d := Distance(hash, Hash(other)); d <= 4 {
+		t.Errorf("different image distance %d, want > 4", d)
+	}
+	if parsed, err := ParseHash(FormatHash(hash)); err != nil || parsed != hash {
+		t.Errorf("ParseHash(FormatHash(%x)) = %x, %v", hash, parsed, err)
+	}
+}
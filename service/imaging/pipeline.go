@@ -0,0 +1,334 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+// Page is a single output page produced from a source image.
+type Page struct {
+	Image    image.Image
+	Rotated  bool
+	Strip    bool   // cut from a long strip, never treated as a spread
+	Modified bool   // the image differs from the source file
+	Suffix   string // distinguishes pages produced from the same image
+}
+
+// Stage transforms a page into zero or more pages.
+type Stage interface {
+	Name() string
+	Apply(page Page) []Page
+}
+
+type stageFunc struct {
+	name string
+	fn   func(Page) []Page
+}
+
+func (s stageFunc) Name() string           { return s.name }
+func (s stageFunc) Apply(page Page) []Page { return s.fn(page) }
+
+// NewStage creates a stage from a function.
+func NewStage(name string, fn func(Page) []Page) Stage {
+	return stageFunc{name: name, fn: fn}
+}
+
+// Config selects the stages of a pipeline for an output format.
+type Config struct {
+	Crop     CropOption
+	Webtoon  bool // split long strips into pages
+	Split    SplitOption
+	Spread   SpreadPolicy
+	RTL      bool
+	Device   DeviceOption
+	Encoding Encoding
+	Quality  int // JPEG quality of re-encoded pages
+	Workers  int // pages processed concurrently
+}
+
+// Pipeline decodes source images, runs them through its stages and encodes
+// the resulting pages in memory, never touching the source files.
+type Pipeline struct {
+	Stages   []Stage
+	Encoding Encoding
+	Quality  int
+	Workers  int
+	// Stitch fragments left at the end of a strip onto the next page
+	Stitch *SplitOption
+
+	mu     sync.Mutex
+	report SizeReport
+}
+
+// Output is an encoded page.
+type Output struct {
+	Source   string // source image path
+	Data     []byte
+	Width    int
+	Height   int
+	Rotated  bool
+	Fragment bool
+}
+
+// MediaType returns the mime type of the encoded page.
+func (o Output) MediaType() string {
+	return http.DetectContentType(o.Data)
+}
+
+// DataURL returns the page as an inline data url.
+func (o Output) DataURL() string {
+	return fmt.Sprintf("data:%s;base64,%s", o.MediaType(), base64.StdEncoding.EncodeToString(o.Data))
+}
+
+// NewPipeline builds the chain decode → crop → split/rotate → resize → color → encode.
+func NewPipeline(cfg Config) *Pipeline {
+	p := &Pipeline{Encoding: cfg.Encoding, Quality: cfg.Quality, Workers: cfg.Workers}
+	if cfg.Crop.Enabled() {
+		p.Stages = append(p.Stages, NewStage("crop", func(page Page) []Page {
+			page.Image = AutoCrop(page.Image, cfg.Crop)
+			page.Modified = true
+			return []Page{page}
+		}))
+	}
+	if cfg.Webtoon {
+		p.Stages = append(p.Stages, NewStage("split", func(page Page) []Page {
+			if !IsStrip(page.Image, cfg.Split) {
+				return []Page{page}
+			}
+			pages := make([]Page, 0)
+			for i, img := range SplitStrip(page.Image, cfg.Split) {
+				pages = append(pages, Page{Image: img, Strip: true, Modified: true, Suffix: fmt.Sprintf("%s_split%d", page.Suffix, i+1)})
+			}
+			return pages
+		}))
+		if cfg.Split.Stitch {
+			p.Stitch = &cfg.Split
+		}
+	}
+	p.Stages = append(p.Stages, NewStage("spread", func(page Page) []Page {
+		if page.Strip {
+			return []Page{page}
+		}
+		pages := HandleSpread(page.Image, cfg.Spread, cfg.RTL)
+		if len(pages) == 1 && pages[0].Image == page.Image {
+			return []Page{page}
+		}
+		for i := range pages {
+			pages[i].Modified = true
+			pages[i].Suffix = page.Suffix + pages[i].Suffix
+		}
+		return pages
+	}))
+	if cfg.Device.Profile.Width > 0 {
+		p.Stages = append(p.Stages, NewStage("resize", func(page Page) []Page {
+			page.Image = Fit(page.Image, cfg.Device.Profile)
+			page.Modified = true
+			return []Page{page}
+		}))
+	}
+	color := cfg.Device
+	color.Profile = Profile{}
+	if color.Enabled() {
+		p.Stages = append(p.Stages, NewStage("color", func(page Page) []Page {
+			page.Image = ApplyDevice(page.Image, color)
+			page.Modified = true
+			return []Page{page}
+		}))
+	}
+	if p.Workers <= 0 {
+		p.Workers = 1
+	}
+	if p.Quality <= 0 {
+		p.Quality = 90
+	}
+	if p.Encoding == "" {
+		p.Encoding = EncodeOriginal
+	}
+	return p
+}
+
+// SourcePages returns the downloaded pages of a chapter folder in page order,
+// leaving out skipped file names.
+func SourcePages(folderPath string, skip map[string]bool) ([]string, error) {
+	files, err := os.ReadDir(folderPath)
+	if err != nil {
+		return nil, err
+	}
+	numbers := make([]int, 0, len(files))
+	for _, f := range files {
+		n, err := strconv.Atoi(strings.TrimSuffix(f.Name(), ".jpg"))
+		if f.IsDir() || err != nil || !strings.HasSuffix(f.Name(), ".jpg") || skip[f.Name()] {
+			continue
+		}
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	paths := make([]string, 0, len(numbers))
+	for _, n := range numbers {
+		paths = append(paths, filepath.Join(folderPath, fmt.Sprintf("%d.jpg", n)))
+	}
+	return paths, nil
+}
+
+// Run processes the sources concurrently and returns their pages in order.
+func (p *Pipeline) Run(sources []string) ([]Output, error) {
+	results := make([][]Output, len(sources))
+	sizes := make([]int64, len(sources))
+	errs := make([]error, len(sources))
+
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	for w := 0; w < p.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i], sizes[i], errs[i] = p.process(sources[i])
+			}
+		}()
+	}
+	for i := range sources {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	outputs := make([]Output, 0, len(sources))
+	report := SizeReport{}
+	for i := range results {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("%s: %w", sources[i], errs[i])
+		}
+		outputs = append(outputs, results[i]...)
+		report.Source += sizes[i]
+	}
+	if p.Stitch != nil {
+		var err error
+		if outputs, err = p.stitch(outputs); err != nil {
+			return nil, err
+		}
+	}
+
+	report.Pages = len(outputs)
+	for _, out := range outputs {
+		report.Output += int64(len(out.Data))
+	}
+	p.mu.Lock()
+	p.report.Pages += report.Pages
+	p.report.Source += report.Source
+	p.report.Output += report.Output
+	p.mu.Unlock()
+	return outputs, nil
+}
+
+// Report returns the total size of the sources and pages processed so far.
+func (p *Pipeline) Report() SizeReport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.report
+}
+
+func (p *Pipeline) process(source string) ([]Output, int64, error) {
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return nil, 0, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pages := []Page{{Image: img}}
+	for _, stage := range p.Stages {
+		next := make([]Page, 0, len(pages))
+		for _, page := range pages {
+			next = append(next, stage.Apply(page)...)
+		}
+		pages = next
+	}
+
+	outputs := make([]Output, 0, len(pages))
+	for _, page := range pages {
+		out := Output{
+			Source:  source,
+			Data:    data,
+			Width:   page.Image.Bounds().Dx(),
+			Height:  page.Image.Bounds().Dy(),
+			Rotated: page.Rotated,
+		}
+		if p.Stitch != nil {
+			out.Fragment = IsFragment(page.Image, *p.Stitch)
+		}
+		if page.Modified || p.Encoding != EncodeOriginal {
+			encoded, err := p.encode(page.Image)
+			if err != nil {
+				return nil, 0, err
+			}
+			// Unmodified pages keep the source when recompressing does not help
+			if page.Modified || len(encoded) < len(data) {
+				out.Data = encoded
+			}
+		}
+		outputs = append(outputs, out)
+	}
+	return outputs, int64(len(data)), nil
+}
+
+// stitch joins fragments onto their neighbouring page across sources,
+// decoding only the pages involved.
+func (p *Pipeline) stitch(outputs []Output) ([]Output, error) {
+	res := make([]Output, 0, len(outputs))
+	for _, out := range outputs {
+		n := len(res)
+		if n == 0 || out.Rotated || res[n-1].Rotated || !(out.Fragment || res[n-1].Fragment) {
+			res = append(res, out)
+			continue
+		}
+		prev := res[n-1]
+		w := max(prev.Width, out.Width)
+		if float64(prev.Height+out.Height) > float64(w)*p.Stitch.Ratio*splitTolerance {
+			res = append(res, out)
+			continue
+		}
+
+		top, _, err := image.Decode(bytes.NewReader(prev.Data))
+		if err != nil {
+			return nil, err
+		}
+		bottom, _, err := image.Decode(bytes.NewReader(out.Data))
+		if err != nil {
+			return nil, err
+		}
+		img := stackVertical(top, bottom)
+		data, err := p.encode(img)
+		if err != nil {
+			return nil, err
+		}
+		res[n-1] = Output{
+			Source:   prev.Source,
+			Data:     data,
+			Width:    img.Bounds().Dx(),
+			Height:   img.Bounds().Dy(),
+			Fragment: IsFragment(img, *p.Stitch),
+		}
+	}
+	return res, nil
+}
+
+func (p *Pipeline) encode(img image.Image) ([]byte, error) {
+	return Encode(img, p.Encoding, p.Quality)
+}
@@ -0,0 +1,74 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeJPEG(t *testing.T, path string, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSourcePages(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"10.jpg", "2.jpg", "1.jpg", "cover.jpg", "3.jpg"} {
+		writeJPEG(t, filepath.Join(dir, name), 10, 10)
+	}
+
+	paths, err := SourcePages(dir, map[string]bool{"3.jpg": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1.jpg", "2.jpg", "10.jpg"}
+	if len(paths) != len(want) {
+		t.Fatalf("got %v, want %v", paths, want)
+	}
+	for i := range want {
+		if filepath.Base(paths[i]) != want[i] {
+			t.Errorf("page %d: got %s, want %s", i, filepath.Base(paths[i]), want[i])
+		}
+	}
+}
+
+func TestPipelineRun(t *testing.T) {
+	dir := t.TempDir()
+	portrait := writeJPEG(t, filepath.Join(dir, "1.jpg"), 100, 200)
+	writeJPEG(t, filepath.Join(dir, "2.jpg"), 200, 100)
+
+	p := NewPipeline(Config{Spread: SpreadSplit, Workers: 2})
+	outputs, err := p.Run([]string{filepath.Join(dir, "1.jpg"), filepath.Join(dir, "2.jpg")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 3 {
+		t.Fatalf("got %d pages, want portrait page and two spread halves", len(outputs))
+	}
+	if !bytes.Equal(outputs[0].Data, portrait) {
+		t.Error("unmodified page should keep the source bytes")
+	}
+	for _, out := range outputs[1:] {
+		if out.Width != 100 || out.Height != 100 || out.MediaType() != "image/jpeg" {
+			t.Errorf("spread half: got %dx%d %s", out.Width, out.Height, out.MediaType())
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "2.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img, err := jpeg.Decode(bytes.NewReader(data)); err != nil || img.Bounds().Dx() != 200 {
+		t.Error("source file should be left untouched")
+	}
+}
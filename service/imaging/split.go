@@ -0,0 +1,148 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/anthonynsimon/bild/clone"
+	"github.com/anthonynsimon/bild/transform"
+)
+
+type SplitOption struct {
+	Ratio       float64 // page height / width, e.g. 1.33 for a 1236x1648 screen
+	MinFragment float64 // pages shorter than MinFragment * page height are fragments
+	Stitch      bool    // stitch fragments back onto the neighbouring page
+}
+
+const (
+	// Strips up to this many pages tall are kept as a single page
+	splitTolerance = 1.25
+	// Cuts are searched from this fraction of the page height down to the full page
+	splitSearchFrom = 0.6
+	// Rows whose luminance deviates less than this are considered blank
+	blankRowDeviation = 12.0
+	// Number of rows around a cut which must also be low-detail
+	splitBand = 3
+)
+
+// IsStrip reports whether an image is tall enough to be split into pages.
+func IsStrip(img image.Image, opt SplitOption) bool {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	return opt.Ratio > 0 && float64(h) > float64(w)*opt.Ratio*splitTolerance
+}
+
+// SplitStrip slices a long vertical strip into pages of the screen ratio,
+// cutting at blank or low-detail rows so speech bubbles and panels are not cut
+// through where avoidable.
+func SplitStrip(img image.Image, opt SplitOption) []image.Image {
+	if !IsStrip(img, opt) {
+		return []image.Image{img}
+	}
+
+	src := clone.AsShallowRGBA(img)
+	b := src.Bounds()
+	// Very narrow strips still advance by at least a row per page
+	pageHeight := max(int(float64(b.Dx())*opt.Ratio), 2)
+	scores := rowScores(src)
+
+	pages := make([]image.Image, 0)
+	for y := 0; y < b.Dy(); {
+		end := y + pageHeight
+		if float64(b.Dy()-y) <= float64(pageHeight)*splitTolerance {
+			end = b.Dy()
+		} else {
+			end = max(findCut(scores, y+int(float64(pageHeight)*splitSearchFrom), end), y+1)
+		}
+		pages = append(pages, transform.Crop(src, image.Rect(b.Min.X, b.Min.Y+y, b.Max.X, b.Min.Y+end)))
+		y = end
+	}
+
+	if opt.Stitch {
+		pages = StitchFragments(pages, opt)
+	}
+	return pages
+}
+
+// StitchFragments appends pages shorter than the fragment limit onto the
+// previous page, or onto the next page when they come first, as long as the
+// stitched page does not grow past the split tolerance.
+func StitchFragments(pages []image.Image, opt SplitOption) []image.Image {
+	if len(pages) < 2 || opt.Ratio <= 0 {
+		return pages
+	}
+	fits := func(a, b image.Image) bool {
+		w := math.Max(float64(a.Bounds().Dx()), float64(b.Bounds().Dx()))
+		return float64(a.Bounds().Dy()+b.Bounds().Dy()) <= w*opt.Ratio*splitTolerance
+	}
+
+	res := make([]image.Image, 0, len(pages))
+	for _, page := range pages {
+		if n := len(res); n > 0 && (IsFragment(page, opt) || IsFragment(res[n-1], opt)) && fits(res[n-1], page) {
+			res[n-1] = stackVertical(res[n-1], page)
+			continue
+		}
+		res = append(res, page)
+	}
+	return res
+}
+
+// IsFragment reports whether a page is too short to stand on its own.
+func IsFragment(img image.Image, opt SplitOption) bool {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	return float64(h) < float64(w)*opt.Ratio*opt.MinFragment
+}
+
+// rowScores returns the luminance deviation of every row: 0 for a solid row,
+// growing with the amount of detail.
+func rowScores(img *image.RGBA) []float64 {
+	b := img.Bounds()
+	scores := make([]float64, b.Dy())
+	lum := make([]float64, b.Dx())
+	for y := 0; y < b.Dy(); y++ {
+		sum := 0.0
+		for x := 0; x < b.Dx(); x++ {
+			lum[x] = luminance(img, b.Min.X+x, b.Min.Y+y)
+			sum += lum[x]
+		}
+		mean := sum / float64(b.Dx())
+		dev := 0.0
+		for _, l := range lum {
+			dev = math.Max(dev, math.Abs(l-mean))
+		}
+		scores[y] = dev
+	}
+	return scores
+}
+
+// findCut returns the row in [from, to) closest to to whose band is blank,
+// falling back to the row with the least detail.
+func findCut(scores []float64, from, to int) int {
+	best, bestScore := to, math.Inf(1)
+	for y := to - 1; y >= from; y-- {
+		score := 0.0
+		for i := max(y-splitBand, 0); i <= min(y+splitBand, len(scores)-1); i++ {
+			score = math.Max(score, scores[i])
+		}
+		if score < blankRowDeviation {
+			return y
+		}
+		if score < bestScore {
+			best, bestScore = y, score
+		}
+	}
+	return best
+}
+
+func stackVertical(top, bottom image.Image) image.Image {
+	w := max(top.Bounds().Dx(), bottom.Bounds().Dx())
+	h := top.Bounds().Dy() + bottom.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+
+	x := (w - top.Bounds().Dx()) / 2
+	draw.Draw(dst, image.Rect(x, 0, x+top.Bounds().Dx(), top.Bounds().Dy()), top, top.Bounds().Min, draw.Src)
+	x = (w - bottom.Bounds().Dx()) / 2
+	draw.Draw(dst, image.Rect(x, top.Bounds().Dy(), x+bottom.Bounds().Dx(), h), bottom, bottom.Bounds().Min, draw.Src)
+	return dst
+}
@@ -0,0 +1,89 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+	"time"
+)
+
+// newStrip draws a white strip with a busy panel between every pair of gaps.
+func newStrip(w, h int, gaps []int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	prev := 0
+	for _, gap := range append(gaps, h) {
+		for y := prev + 10; y < gap-10; y++ {
+			for x := 0; x < w; x++ {
+				img.Set(x, y, color.Gray{Y: uint8((x*7 + y*13) % 256)})
+			}
+		}
+		prev = gap
+	}
+	return img
+}
+
+func TestSplitStripCutsAtBlankRows(t *testing.T) {
+	opt := SplitOption{Ratio: 1.5, MinFragment: 0.25}
+	gaps := []int{130, 280, 410}
+	pages := SplitStrip(newStrip(100, 560, gaps), opt)
+	if len(pages) < 2 {
+		t.Fatalf("got %d pages, want the strip to be split", len(pages))
+	}
+
+	total := 0
+	for i, page := range pages[:len(pages)-1] {
+		end := page.Bounds().Max.Y
+		near := false
+		for _, gap := range gaps {
+			if end >= gap-10 && end <= gap+10 {
+				near = true
+			}
+		}
+		if !near {
+			t.Errorf("page %d cut at row %d, not in a blank gap", i, end)
+		}
+		total += page.Bounds().Dy()
+	}
+	total += pages[len(pages)-1].Bounds().Dy()
+	if total != 560 {
+		t.Errorf("pages cover %d rows, want 560", total)
+	}
+}
+
+func TestSplitStripNarrow(t *testing.T) {
+	opt := SplitOption{Ratio: 1.4, MinFragment: 0.25}
+	for _, w := range []int{1, 2, 3} {
+		done := make(chan []image.Image)
+		go func() { done <- SplitStrip(newStrip(w, 50, nil), opt) }()
+		select {
+		case pages := <-done:
+			total := 0
+			for _, page := range pages {
+				total += page.Bounds().Dy()
+			}
+			if total != 50 {
+				t.Errorf("%dpx wide: pages cover %d rows, want 50", w, total)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%dpx wide: split did not finish", w)
+		}
+	}
+}
+
+func TestStitchFragments(t *testing.T) {
+	opt := SplitOption{Ratio: 1.5, MinFragment: 0.25}
+	pages := []image.Image{
+		image.NewRGBA(image.Rect(0, 0, 100, 140)),
+		image.NewRGBA(image.Rect(0, 0, 100, 20)),
+		image.NewRGBA(image.Rect(0, 0, 100, 150)),
+	}
+	got := StitchFragments(pages, opt)
+	if len(got) != 2 {
+		t.Fatalf("got %d pages, want 2", len(got))
+	}
+	if h := got[0].Bounds().Dy(); h != 160 {
+		t.Errorf("stitched page height %d, want 160", h)
+	}
+}
@@ -0,0 +1,83 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/anthonynsimon/bild/transform"
+)
+
+// SpreadPolicy decides how landscape (double-page spread) images are laid out.
+type SpreadPolicy string
+
+const (
+	SpreadRotate   SpreadPolicy = "rotate"    // rotate counter-clockwise
+	SpreadRotateCW SpreadPolicy = "rotate-cw" // rotate clockwise
+	SpreadSplit    SpreadPolicy = "split"     // split into two pages in reading order
+	SpreadKeep     SpreadPolicy = "keep"      // fit the spread as is
+	SpreadBoth     SpreadPolicy = "both"      // rotated spread followed by both halves
+)
+
+func ParseSpreadPolicy(policy string) (SpreadPolicy, error) {
+	switch p := SpreadPolicy(strings.ToLower(strings.TrimSpace(policy))); p {
+	case "":
+		return SpreadRotate, nil
+	case SpreadRotate, SpreadRotateCW, SpreadSplit, SpreadKeep, SpreadBoth:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown spread policy %q, use one of rotate, rotate-cw, split, keep, both", policy)
+	}
+}
+
+// IsSpread reports whether an image is a landscape double-page spread.
+func IsSpread(img image.Image) bool {
+	return img.Bounds().Dx() > img.Bounds().Dy()
+}
+
+// HandleSpread lays out an image according to the spread policy. Portrait
+// images are returned as is. When splitting, the right half comes first for
+// right-to-left books.
+func HandleSpread(img image.Image, policy SpreadPolicy, rtl bool) []Page {
+	if !IsSpread(img) {
+		return []Page{{Image: img}}
+	}
+
+	switch policy {
+	case SpreadKeep:
+		return []Page{{Image: img}}
+	case SpreadRotateCW:
+		return []Page{rotate(img, 90)}
+	case SpreadSplit:
+		return splitSpread(img, rtl)
+	case SpreadBoth:
+		return append([]Page{rotate(img, -90)}, splitSpread(img, rtl)...)
+	default:
+		return []Page{rotate(img, -90)}
+	}
+}
+
+func rotate(img image.Image, angle float64) Page {
+	return Page{
+		Image:   transform.Rotate(img, angle, &transform.RotationOptions{ResizeBounds: true}),
+		Rotated: true,
+		Suffix:  "_rotated",
+	}
+}
+
+func splitSpread(img image.Image, rtl bool) []Page {
+	b := img.Bounds()
+	mid := b.Min.X + b.Dx()/2
+	left := Page{
+		Image:  transform.Crop(img, image.Rect(b.Min.X, b.Min.Y, mid, b.Max.Y)),
+		Suffix: "_spread_left",
+	}
+	right := Page{
+		Image:  transform.Crop(img, image.Rect(mid, b.Min.Y, b.Max.X, b.Max.Y)),
+		Suffix: "_spread_right",
+	}
+	if rtl {
+		return []Page{right, left}
+	}
+	return []Page{left, right}
+}
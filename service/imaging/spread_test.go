@@ -0,0 +1,43 @@
+package imaging
+
+import (
+	"image"
+	"testing"
+)
+
+func TestHandleSpread(t *testing.T) {
+	spread := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	portrait := image.NewRGBA(image.Rect(0, 0, 100, 200))
+
+	if pages := HandleSpread(portrait, SpreadSplit, false); len(pages) != 1 || pages[0].Suffix != "" {
+		t.Errorf("portrait image should be kept as is, got %d pages", len(pages))
+	}
+
+	pages := HandleSpread(spread, SpreadRotate, false)
+	if len(pages) != 1 || !pages[0].Rotated || pages[0].Image.Bounds().Dx() != 100 {
+		t.Errorf("rotate: got %d pages, want one rotated portrait page", len(pages))
+	}
+
+	tests := []struct {
+		rtl  bool
+		want []string
+	}{
+		{false, []string{"_spread_left", "_spread_right"}},
+		{true, []string{"_spread_right", "_spread_left"}},
+	}
+	for _, tt := range tests {
+		pages := HandleSpread(spread, SpreadSplit, tt.rtl)
+		if len(pages) != 2 {
+			t.Fatalf("split (rtl=%v): got %d pages, want 2", tt.rtl, len(pages))
+		}
+		for i, page := range pages {
+			if page.Suffix != tt.want[i] || page.Image.Bounds().Dx() != 100 {
+				t.Errorf("split (rtl=%v) page %d: got %s %v", tt.rtl, i, page.Suffix, page.Image.Bounds())
+			}
+		}
+	}
+
+	if pages := HandleSpread(spread, SpreadBoth, true); len(pages) != 3 || !pages[0].Rotated {
+		t.Errorf("both: got %d pages, want rotated spread and two halves", len(pages))
+	}
+}
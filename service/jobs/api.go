@@ -0,0 +1,149 @@
+package jobs
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/vukyn/kuery/log"
+)
+
+// Interval between reads of a running job log
+const followInterval = 500 * time.Millisecond
+
+type api struct {
+	queue *Queue
+	token string
+}
+
+// Handler returns the HTTP API of a queue. Requests need the bearer token,
+// every request is refused when it is empty.
+func Handler(q *Queue, token string) http.Handler {
+	a := &api{queue: q, token: token}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/jobs", a.submit)
+	mux.HandleFunc("GET /api/jobs", a.list)
+	mux.HandleFunc("GET /api/jobs/{id}", a.get)
+	mux.HandleFunc("POST /api/jobs/{id}/cancel", a.cancel)
+	mux.HandleFunc("GET /api/jobs/{id}/logs", a.logs)
+	return a.authorize(mux)
+}
+
+func (a *api) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if a.token == "" || subtle.ConstantTimeCompare(got, []byte("Bearer "+a.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid or missing bearer token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *api) submit(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	job, err := a.queue.Submit(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.Header().Set("Location", "/api/jobs/"+job.Id)
+	writeJSON(w, http.StatusCreated, job)
+}
+
+func (a *api) list(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.queue.List())
+}
+
+func (a *api) get(w http.ResponseWriter, r *http.Request) {
+	job, ok := a.queue.Get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, os.ErrNotExist)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (a *api) cancel(w http.ResponseWriter, r *http.Request) {
+	job, err := a.queue.Cancel(r.PathValue("id"))
+	if errors.Is(err, os.ErrNotExist) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// logs streams the log of a job, following it until the job finishes unless
+// follow=false.
+func (a *api) logs(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := a.queue.Get(id); !ok {
+		writeError(w, http.StatusNotFound, os.ErrNotExist)
+		return
+	}
+	follow := r.URL.Query().Get("follow") != "false"
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	flusher, _ := w.(http.Flusher)
+
+	var offset int64
+	for {
+		// Read the status first so the lines written before it finished are sent
+		job, _ := a.queue.Get(id)
+		n, err := copyFrom(w, a.queue.LogPath(id), offset)
+		if err != nil {
+			log.Errorf("Failed to stream log of job %s: %v", id, err)
+			return
+		}
+		offset += n
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if !follow || job.Status.Finished() {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(followInterval):
+		}
+	}
+}
+
+// copyFrom writes a file from offset, nothing if it does not exist yet.
+func copyFrom(w io.Writer, path string, offset int64) (int64, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.Copy(w, f)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("Failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,339 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"comic-crawler/env"
+
+	"github.com/vukyn/kuery/log"
+)
+
+// StateFile persists the jobs of a queue folder, next to one log per job.
+const StateFile = "jobs.json"
+
+type Kind string
+
+const (
+	Crawl   Kind = "crawl"
+	Convert Kind = "convert"
+)
+
+type Status string
+
+const (
+	Queued   Status = "queued"
+	Running  Status = "running"
+	Done     Status = "done"
+	Failed   Status = "failed"
+	Canceled Status = "canceled"
+)
+
+// Finished reports whether a job with this status will not run anymore.
+func (s Status) Finished() bool {
+	return s == Done || s == Failed || s == Canceled
+}
+
+// Request describes the work of a job.
+type Request struct {
+	Kind    Kind   `json:"kind"`
+	Source  string `json:"source"` // site name or domain
+	ComicId int    `json:"comicId"`
+	// Chapter list url of sources without comic ids, required to crawl
+	// qqtruyen
+	Query string `json:"query,omitempty"`
+	// Chapters to crawl: all (default), a range 1-10 or a list 1,2,5
+	Chapters string `json:"chapters,omitempty"`
+	// Formats to convert to, CONVERT_FORMAT by default
+	Formats string `json:"formats,omitempty"`
+	// Rebuild converted chapters which are up to date
+	Force bool `json:"force,omitempty"`
+	// Series metadata of converted chapters, convert jobs need a title
+	Title       string `json:"title,omitempty"`
+	Author      string `json:"author,omitempty"`
+	Description string `json:"description,omitempty"`
+	Subjects    string `json:"subjects,omitempty"` // comma separated genres
+}
+
+func (r Request) validate() error {
+	if r.Kind != Crawl && r.Kind != Convert {
+		return fmt.Errorf("unknown job kind %q, use one of crawl, convert", r.Kind)
+	}
+	if r.Source == "" {
+		return errors.New("missing source")
+	}
+	if r.ComicId <= 0 {
+		return errors.New("missing comic id")
+	}
+	if r.Kind == Crawl && strings.TrimSpace(r.Query) == "" && isQqtruyen(r.Source) {
+		return errors.New("missing query, the chapter list url of the qqtruyen series")
+	}
+	if r.Kind == Convert && strings.TrimSpace(r.Title) == "" {
+		return errors.New("missing title of the series to convert")
+	}
+	return nil
+}
+
+// isQqtruyen reports whether source names qqtruyen by site name or domain.
+func isQqtruyen(source string) bool {
+	source = strings.ToLower(strings.TrimSpace(source))
+	return source == "qqtruyen" || source == env.QqtruyenDomain || source == "www."+env.QqtruyenDomain
+}
+
+type Job struct {
+	Id string `json:"id"`
+	Request
+	Status     Status     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// Runner runs a job until done or canceled through ctx, writing its log to w.
+type Runner func(ctx context.Context, job Job, w io.Writer) error
+
+// Queue runs submitted jobs in order with a fixed number of workers. Jobs
+// are saved in a folder so a restarted queue resumes them.
+type Queue struct {
+	dir  string
+	run  Runner
+	mu   sync.Mutex
+	cond *sync.Cond
+	jobs []*Job
+	// Cancel functions of running jobs by id
+	cancels map[string]context.CancelFunc
+	nextId  int
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// Open loads the jobs saved in dir and starts the workers. Jobs interrupted
+// while running are queued again.
+func Open(dir string, workers int, run Runner) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	q := &Queue{dir: dir, run: run, cancels: make(map[string]context.CancelFunc), nextId: 1}
+	q.cond = sync.NewCond(&q.mu)
+
+	data, err := os.ReadFile(filepath.Join(dir, StateFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &q.jobs); err != nil {
+			return nil, fmt.Errorf("%s: %w", StateFile, err)
+		}
+	}
+	for _, job := range q.jobs {
+		if job.Status == Running {
+			job.Status, job.StartedAt = Queued, nil
+		}
+		if id, err := strconv.Atoi(job.Id); err == nil && id >= q.nextId {
+			q.nextId = id + 1
+		}
+	}
+
+	for i := 0; i < max(1, workers); i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q, nil
+}
+
+// Close cancels the running jobs and waits for the workers to stop. Canceled
+// jobs are queued again when the queue is reopened.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	q.closed = true
+	for _, cancel := range q.cancels {
+		cancel()
+	}
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+func (q *Queue) Submit(req Request) (Job, error) {
+	if err := req.validate(); err != nil {
+		return Job{}, err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job := &Job{Id: strconv.Itoa(q.nextId), Request: req, Status: Queued, CreatedAt: time.Now()}
+	q.nextId++
+	q.jobs = append(q.jobs, job)
+	if err := q.save(); err != nil {
+		return Job{}, err
+	}
+	q.cond.Signal()
+	return *job, nil
+}
+
+// List returns every job, oldest first.
+func (q *Queue) List() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	res := make([]Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		res = append(res, *job)
+	}
+	return res
+}
+
+func (q *Queue) Get(id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if job := q.find(id); job != nil {
+		return *job, true
+	}
+	return Job{}, false
+}
+
+// Cancel drops a queued job or stops a running one.
+func (q *Queue) Cancel(id string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job := q.find(id)
+	if job == nil {
+		return Job{}, os.ErrNotExist
+	}
+	switch job.Status {
+	case Queued:
+		now := time.Now()
+		job.Status, job.FinishedAt = Canceled, &now
+		if err := q.save(); err != nil {
+			return Job{}, err
+		}
+	case Running:
+		q.cancels[id]()
+	default:
+		return *job, fmt.Errorf("job %s is already %s", id, job.Status)
+	}
+	return *job, nil
+}
+
+// LogPath returns the log file of a job, written while it runs.
+func (q *Queue) LogPath(id string) string {
+	return filepath.Join(q.dir, id+".log")
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for {
+		q.mu.Lock()
+		job := q.next()
+		for job == nil && !q.closed {
+			q.cond.Wait()
+			job = q.next()
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		started := time.Now()
+		job.Status, job.StartedAt = Running, &started
+		q.cancels[job.Id] = cancel
+		if err := q.save(); err != nil {
+			log.Errorf("Failed to save jobs: %v", err)
+		}
+		snapshot := *job
+		q.mu.Unlock()
+
+		err := q.runJob(ctx, snapshot)
+		canceled := ctx.Err() != nil
+		cancel()
+
+		q.mu.Lock()
+		delete(q.cancels, job.Id)
+		finished := time.Now()
+		job.FinishedAt = &finished
+		switch {
+		case q.closed && canceled:
+			// Interrupted by Close, resumed when reopened
+			job.Status, job.StartedAt, job.FinishedAt = Queued, nil, nil
+		case canceled:
+			job.Status = Canceled
+		case err != nil:
+			job.Status, job.Error = Failed, err.Error()
+		default:
+			job.Status = Done
+		}
+		if err := q.save(); err != nil {
+			log.Errorf("Failed to save jobs: %v", err)
+		}
+		q.mu.Unlock()
+	}
+}
+
+func (q *Queue) runJob(ctx context.Context, job Job) error {
+	f, err := os.OpenFile(q.LogPath(job.Id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := &lockedWriter{w: f}
+	fmt.Fprintf(w, "%s Starting %s job for %s %d\n", time.Now().Format(time.DateTime), job.Kind, job.Source, job.ComicId)
+	err = q.run(ctx, job, w)
+	if err != nil {
+		fmt.Fprintf(w, "%s Job failed: %v\n", time.Now().Format(time.DateTime), err)
+	} else {
+		fmt.Fprintf(w, "%s Job done\n", time.Now().Format(time.DateTime))
+	}
+	return err
+}
+
+// next returns the oldest queued job, the caller holds the lock.
+func (q *Queue) next() *Job {
+	for _, job := range q.jobs {
+		if job.Status == Queued {
+			return job
+		}
+	}
+	return nil
+}
+
+func (q *Queue) find(id string) *Job {
+	for _, job := range q.jobs {
+		if job.Id == id {
+			return job
+		}
+	}
+	return nil
+}
+
+// save writes the jobs to the state file, the caller holds the lock.
+func (q *Queue) save() error {
+	data, err := json.MarshalIndent(q.jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(q.dir, StateFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(q.dir, StateFile))
+}
+
+// lockedWriter serializes the writes of concurrent conversion workers.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
@@ -0,0 +1,176 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRunner fails jobs of comic 13, blocks jobs of comic 99 until canceled
+// and completes the others.
+func testRunner(ctx context.Context, job Job, w io.Writer) error {
+	fmt.Fprintf(w, "working on %d\n", job.ComicId)
+	switch job.ComicId {
+	case 13:
+		return errors.New("comic not found")
+	case 99:
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func waitStatus(t *testing.T, q *Queue, id string, want Status) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, _ := q.Get(id); job.Status == want {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	job, _ := q.Get(id)
+	t.Fatalf("job %s is %s, want %s", id, job.Status, want)
+	return job
+}
+
+func TestQueue(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir, 1, testRunner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := q.Submit(Request{Kind: "delete", Source: "nettruyen", ComicId: 1}); err == nil {
+		t.Error("unknown kind should fail")
+	}
+	if _, err := q.Submit(Request{Kind: Convert, Source: "nettruyen", ComicId: 13}); err == nil {
+		t.Error("convert without series title should fail")
+	}
+	if _, err := q.Submit(Request{Kind: Crawl, Source: "qqtruyen", ComicId: 7}); err == nil {
+		t.Error("qqtruyen crawl without query should fail")
+	}
+	done, _ := q.Submit(Request{Kind: Crawl, Source: "nettruyen", ComicId: 1})
+	failed, _ := q.Submit(Request{Kind: Convert, Source: "nettruyen", ComicId: 13, Title: "Conan"})
+	blocked, _ := q.Submit(Request{Kind: Crawl, Source: "nettruyen", ComicId: 99})
+	queued, _ := q.Submit(Request{Kind: Crawl, Source: "nettruyen", ComicId: 2})
+
+	waitStatus(t, q, done.Id, Done)
+	if job := waitStatus(t, q, failed.Id, Failed); job.Error != "comic not found" {
+		t.Errorf("error %q", job.Error)
+	}
+	waitStatus(t, q, blocked.Id, Running)
+
+	// A single worker is busy, the last job waits and resumes after a restart
+	q.Close()
+	q, err = Open(dir, 1, testRunner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	waitStatus(t, q, blocked.Id, Running)
+	if job, _ := q.Get(queued.Id); job.Status != Queued {
+		t.Errorf("job %s is %s, want queued", queued.Id, job.Status)
+	}
+	if _, err := q.Cancel(blocked.Id); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, q, blocked.Id, Canceled)
+	waitStatus(t, q, queued.Id, Done)
+	if _, err := q.Cancel(queued.Id); err == nil {
+		t.Error("canceling a finished job should fail")
+	}
+	if next, _ := q.Submit(Request{Kind: Crawl, Source: "nettruyen", ComicId: 3}); next.Id != "5" {
+		t.Errorf("next id %s, want 5", next.Id)
+	}
+}
+
+func TestAPI(t *testing.T) {
+	q, err := Open(t.TempDir(), 2, testRunner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	srv := httptest.NewServer(Handler(q, "secret"))
+	defer srv.Close()
+
+	do := func(method, path, token string, body any) *http.Response {
+		t.Helper()
+		var data []byte
+		if body != nil {
+			data, _ = json.Marshal(body)
+		}
+		req, _ := http.NewRequest(method, srv.URL+path, bytes.NewReader(data))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	if res := do("GET", "/api/jobs", "", nil); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("missing token: status %d", res.StatusCode)
+	}
+	open := httptest.NewServer(Handler(q, ""))
+	defer open.Close()
+	req, _ := http.NewRequest("GET", open.URL+"/api/jobs", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	if res, err := http.DefaultClient.Do(req); err != nil || res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("handler without token: %v, want status %d", err, http.StatusUnauthorized)
+	}
+	if res := do("POST", "/api/jobs", "secret", Request{Kind: Crawl}); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid request: status %d", res.StatusCode)
+	}
+
+	res := do("POST", "/api/jobs", "secret", Request{Kind: Crawl, Source: "nettruyen", ComicId: 99, Chapters: "1-3"})
+	var job Job
+	json.NewDecoder(res.Body).Decode(&job)
+	res.Body.Close()
+	if res.StatusCode != http.StatusCreated || job.Chapters != "1-3" || res.Header.Get("Location") != "/api/jobs/"+job.Id {
+		t.Fatalf("submit: status %d, job %+v", res.StatusCode, job)
+	}
+	waitStatus(t, q, job.Id, Running)
+
+	// Follow the log until the job is canceled
+	logs := make(chan string)
+	go func() {
+		res := do("GET", "/api/jobs/"+job.Id+"/logs", "secret", nil)
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		logs <- string(data)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if res := do("POST", "/api/jobs/"+job.Id+"/cancel", "secret", nil); res.StatusCode != http.StatusAccepted {
+		t.Errorf("cancel: status %d", res.StatusCode)
+	}
+	select {
+	case got := <-logs:
+		if !strings.Contains(got, "working on 99") || !strings.Contains(got, "Job failed: context canceled") {
+			t.Errorf("log: %q", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("log stream did not end with the job")
+	}
+
+	var list []Job
+	res = do("GET", "/api/jobs", "secret", nil)
+	json.NewDecoder(res.Body).Decode(&list)
+	res.Body.Close()
+	if len(list) != 1 || list[0].Status != Canceled {
+		t.Errorf("list: %+v", list)
+	}
+	if res := do("GET", "/api/jobs/42", "secret", nil); res.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown job: status %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,192 @@
+package library
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"comic-crawler/service/crawler"
+)
+
+// Folders written next to the chapter folders of a comic
+var outputDirs = map[string]bool{
+	"epub": true,
+	"pdf":  true,
+	"cbz":  true,
+	"html": true,
+	"temp": true,
+}
+
+// Series is a downloaded comic, stored in <root>/<site>/<id>.
+type Series struct {
+	Site string
+	Id   string
+	Path string
+}
+
+// IsChapter reports whether a folder entry of a comic holds chapter pages.
+func IsChapter(f os.DirEntry) bool {
+	return f.IsDir() && IsChapterName(f.Name())
+}
+
+// IsChapterName reports whether a folder of a comic with this name is a
+// chapter: not an output folder nor hidden.
+func IsChapterName(name string) bool {
+	return !outputDirs[strings.ToLower(name)] && !strings.HasPrefix(name, ".")
+}
+
+// ListSeries returns every comic downloaded under root, by site then id.
+func ListSeries(root string) ([]Series, error) {
+	sites, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Series, 0)
+	for _, site := range sites {
+		if !site.IsDir() {
+			continue
+		}
+		comics, err := os.ReadDir(filepath.Join(root, site.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, comic := range comics {
+			if comic.IsDir() {
+				res = append(res, Series{
+					Site: site.Name(),
+					Id:   comic.Name(),
+					Path: filepath.Join(root, site.Name(), comic.Name()),
+				})
+			}
+		}
+	}
+	return res, nil
+}
+
+// Chapters returns the chapter folders of a comic in reading order.
+func Chapters(comicPath string) ([]string, error) {
+	files, err := os.ReadDir(comicPath)
+	if err != nil {
+		return nil, err
+	}
+	chapters := make([]string, 0)
+	for _, f := range files {
+		if IsChapter(f) {
+			chapters = append(chapters, f.Name())
+		}
+	}
+	SortChapters(chapters)
+	return chapters, nil
+}
+
+// SortChapters orders chapter folders in the reading order of the crawler:
+// by volume, then chapter number, keeping unnumbered chapters last.
+func SortChapters(chapters []string) {
+	keys := make(map[string]string, len(chapters))
+	for i, chapter := range chapters {
+		keys[chapter] = crawler.ChapterSortKey(chapter, i)
+	}
+	sort.SliceStable(chapters, func(i, j int) bool {
+		return keys[chapters[i]] < keys[chapters[j]]
+	})
+}
+
+// ValidName reports whether a name taken from a request is a single path
+// element, so joining it cannot escape the library.
+func ValidName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid name %q", name)
+	}
+	return nil
+}
+
+// EscapePath joins the path elements of a library file escaped for urls.
+func EscapePath(elems ...string) string {
+	for i, elem := range elems {
+		elems[i] = url.PathEscape(elem)
+	}
+	return path.Join(elems...)
+}
+
+// Format is an output format of converted chapters, stored in
+// <comic>/<Name>/<chapter>.<Name>.
+type Format struct {
+	Name      string
+	MediaType string
+}
+
+// Formats of converted chapters readers can download
+var Formats = []Format{
+	{"epub", "application/epub+zip"},
+	{"pdf", "application/pdf"},
+	{"cbz", "application/vnd.comicbook+zip"},
+}
+
+// File is a converted chapter in one format.
+type File struct {
+	Format   Format
+	Path     string
+	Size     int64
+	Modified time.Time
+}
+
+// Book is a converted chapter with its files in every format.
+type Book struct {
+	Chapter  string
+	Modified time.Time // newest file
+	Files    []File
+}
+
+// Books returns the converted chapters of a comic in reading order, from the
+// output folders so chapters whose pages were deleted are still listed.
+func Books(comicPath string) ([]Book, error) {
+	books := make(map[string]*Book)
+	for _, format := range Formats {
+		entries, err := os.ReadDir(filepath.Join(comicPath, format.Name))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			chapter, ok := strings.CutSuffix(entry.Name(), "."+format.Name)
+			if entry.IsDir() || !ok {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return nil, err
+			}
+			book, ok := books[chapter]
+			if !ok {
+				book = &Book{Chapter: chapter}
+				books[chapter] = book
+			}
+			book.Files = append(book.Files, File{
+				Format:   format,
+				Path:     filepath.Join(comicPath, format.Name, entry.Name()),
+				Size:     info.Size(),
+				Modified: info.ModTime(),
+			})
+			if info.ModTime().After(book.Modified) {
+				book.Modified = info.ModTime()
+			}
+		}
+	}
+
+	chapters := make([]string, 0, len(books))
+	for chapter := range books {
+		chapters = append(chapters, chapter)
+	}
+	SortChapters(chapters)
+	res := make([]Book, 0, len(chapters))
+	for _, chapter := range chapters {
+		res = append(res, *books[chapter])
+	}
+	return res, nil
+}
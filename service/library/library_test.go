@@ -0,0 +1,84 @@
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestChapters(t *testing.T) {
+	root := t.TempDir()
+	comic := filepath.Join(root, "nettruyen", "42")
+	for _, dir := range []string{"Chương 10", "Chương 2", "Oneshot", "Chương 1.5", "epub", "html", ".cache"} {
+		if err := os.MkdirAll(filepath.Join(comic, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(comic, "cover.jpg"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	chapters, err := Chapters(comic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Chương 1.5", "Chương 2", "Chương 10", "Oneshot"}; !reflect.DeepEqual(chapters, want) {
+		t.Errorf("got %q, want %q", chapters, want)
+	}
+
+	series, err := ListSeries(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 1 || series[0].Site != "nettruyen" || series[0].Id != "42" || series[0].Path != comic {
+		t.Errorf("got %+v", series)
+	}
+}
+
+func TestSortChapters(t *testing.T) {
+	// Folder names as crawled, reserved characters replaced
+	chapters := []string{"Extra", "Vol.2 Chapter 1", "Chapter 12_ Side Story", "Chapter 12", "Vol.1 Chapter 3", "Chapter 11.5", "Afterword"}
+	SortChapters(chapters)
+	want := []string{"Vol.1 Chapter 3", "Vol.2 Chapter 1", "Chapter 11.5", "Chapter 12", "Chapter 12_ Side Story", "Extra", "Afterword"}
+	if !reflect.DeepEqual(chapters, want) {
+		t.Errorf("got %q, want %q", chapters, want)
+	}
+}
+
+func TestBooks(t *testing.T) {
+	comic := t.TempDir()
+	for _, f := range []string{"epub/Chương 10.epub", "epub/Chương 2.epub", "cbz/Chương 2.cbz", "epub/fingerprints.json", "html/Chương 2/index.html"} {
+		path := filepath.Join(comic, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("book"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	books, err := Books(comic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(books) != 2 || books[0].Chapter != "Chương 2" || books[1].Chapter != "Chương 10" {
+		t.Fatalf("got %+v", books)
+	}
+	if files := books[0].Files; len(files) != 2 || files[0].Format.Name != "epub" || files[1].Format.Name != "cbz" || files[1].Size != 4 {
+		t.Errorf("got files %+v", files)
+	}
+}
+
+func TestValidName(t *testing.T) {
+	for _, name := range []string{"Chương 1", "1.jpg", "..a"} {
+		if err := ValidName(name); err != nil {
+			t.Errorf("%q: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", ".", "..", "../etc", `a\b`} {
+		if ValidName(name) == nil {
+			t.Errorf("%q should be invalid", name)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package opds
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// feed is an OPDS 1.2 Atom feed, converted to OPDS 2.0 by v2.
+type feed struct {
+	XMLName   xml.Name `xml:"feed"`
+	Xmlns     string   `xml:"xmlns,attr"`
+	XmlnsOpds string   `xml:"xmlns:opds,attr"`
+	Id        string   `xml:"id"`
+	Title     string   `xml:"title"`
+	Updated   string   `xml:"updated"`
+	Author    author   `xml:"author"`
+	Links     []link   `xml:"link"`
+	Entries   []entry  `xml:"entry"`
+	kind      string
+}
+
+type author struct {
+	Name string `xml:"name"`
+}
+
+type link struct {
+	Rel    string `xml:"rel,attr"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr"`
+	Title  string `xml:"title,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type content struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+type entry struct {
+	Title   string   `xml:"title"`
+	Id      string   `xml:"id"`
+	Updated string   `xml:"updated"`
+	Content *content `xml:"content,omitempty"`
+	Links   []link   `xml:"link"`
+	// Series of a book and its position, OPDS 2.0 only
+	Series   string  `xml:"-"`
+	Position float64 `xml:"-"`
+}
+
+func newFeed(r *http.Request, title, kind string, updated time.Time) feed {
+	self := kind
+	if isV2(r) {
+		self = jsonType
+	}
+	return feed{
+		Xmlns:     "http://www.w3.org/2005/Atom",
+		XmlnsOpds: "http://opds-spec.org/2010/catalog",
+		Id:        "urn:comic-crawler:" + strings.TrimPrefix(r.URL.Path, "/"),
+		Title:     title,
+		Updated:   formatTime(updated),
+		Author:    author{Name: "comic-crawler"},
+		Links: []link{
+			{Rel: "self", Href: r.URL.Path, Type: self},
+			{Rel: "start", Href: basePath(r), Type: strings.Replace(self, "acquisition", "navigation", 1)},
+		},
+		kind: kind,
+	}
+}
+
+func navigationEntry(id, title, text, href, kind string, updated time.Time) entry {
+	e := entry{
+		Id:      id,
+		Title:   title,
+		Updated: formatTime(updated),
+		Links:   []link{{Rel: "subsection", Href: href, Type: kind}},
+	}
+	if text != "" {
+		e.Content = &content{Type: "text", Text: text}
+	}
+	return e
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return strconv.Itoa(n) + " " + word + "s"
+}
+
+// OPDS 2.0 documents
+
+type feedV2 struct {
+	Metadata     metadataV2      `json:"metadata"`
+	Links        []linkV2        `json:"links"`
+	Navigation   []linkV2        `json:"navigation,omitempty"`
+	Publications []publicationV2 `json:"publications,omitempty"`
+}
+
+type metadataV2 struct {
+	Type          string       `json:"@type,omitempty"`
+	Title         string       `json:"title"`
+	Identifier    string       `json:"identifier,omitempty"`
+	Modified      string       `json:"modified,omitempty"`
+	Description   string       `json:"description,omitempty"`
+	NumberOfItems int          `json:"numberOfItems,omitempty"`
+	BelongsTo     *belongsToV2 `json:"belongsTo,omitempty"`
+}
+
+type belongsToV2 struct {
+	Series []seriesV2 `json:"series"`
+}
+
+type seriesV2 struct {
+	Name     string   `json:"name"`
+	Position *float64 `json:"position,omitempty"`
+}
+
+type linkV2 struct {
+	Rel    string `json:"rel,omitempty"`
+	Href   string `json:"href"`
+	Type   string `json:"type,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Length int64  `json:"length,omitempty"`
+}
+
+type publicationV2 struct {
+	Metadata metadataV2 `json:"metadata"`
+	Links    []linkV2   `json:"links"`
+	Images   []linkV2   `json:"images,omitempty"`
+}
+
+// v2 converts the feed to OPDS 2.0: entries with acquisition links become
+// publications, the others navigation links.
+func (f feed) v2() feedV2 {
+	res := feedV2{Metadata: metadataV2{Title: f.Title, Modified: f.Updated}, Links: make([]linkV2, 0)}
+	for _, l := range f.Links {
+		res.Links = append(res.Links, linkV2{Rel: l.Rel, Href: l.Href, Type: jsonType})
+	}
+	for _, e := range f.Entries {
+		p := publicationV2{
+			Metadata: metadataV2{Type: "http://schema.org/Book", Title: e.Title, Identifier: e.Id, Modified: e.Updated},
+			Links:    make([]linkV2, 0),
+		}
+		if e.Series != "" {
+			s := seriesV2{Name: e.Series}
+			if e.Position != 0 {
+				s.Position = &e.Position
+			}
+			p.Metadata.BelongsTo = &belongsToV2{Series: []seriesV2{s}}
+		}
+		for _, l := range e.Links {
+			switch l.Rel {
+			case "subsection":
+				res.Navigation = append(res.Navigation, linkV2{Rel: l.Rel, Href: l.Href, Type: jsonType, Title: e.Title})
+			case relImage:
+				p.Images = append(p.Images, linkV2{Href: l.Href, Type: l.Type})
+			case relThumbnail:
+				// OPDS 2.0 lists images without the thumbnail relation
+			default:
+				p.Links = append(p.Links, linkV2{Rel: l.Rel, Href: l.Href, Type: l.Type, Length: l.Length})
+			}
+		}
+		if len(p.Links) > 0 {
+			res.Publications = append(res.Publications, p)
+		}
+	}
+	res.Metadata.NumberOfItems = len(res.Publications)
+	return res
+}
@@ -0,0 +1,296 @@
+package opds
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"comic-crawler/service/cover"
+	"comic-crawler/service/crawler"
+	"comic-crawler/service/epub"
+	"comic-crawler/service/library"
+
+	"github.com/vukyn/kuery/log"
+)
+
+// Media types of OPDS 1.2 feeds and OPDS 2.0 documents
+const (
+	navigationType  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	acquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	jsonType        = "application/opds+json"
+)
+
+// Link relations
+const (
+	relAcquisition = "http://opds-spec.org/acquisition"
+	relImage       = "http://opds-spec.org/image"
+	relThumbnail   = "http://opds-spec.org/image/thumbnail"
+	relNew         = "http://opds-spec.org/sort/new"
+)
+
+// Number of books listed in the latest additions
+const latestLimit = 50
+
+type Option struct {
+	Root string // library folder, out/<site>/<id>/<format>/<chapter>.<format>
+	// Series titles by "<site>/<id>", the id is shown otherwise
+	Titles map[string]string
+	// Url paths serving the library files and their thumbnails
+	FilesPath  string
+	ThumbsPath string
+}
+
+type catalog struct {
+	opt Option
+}
+
+// series is a comic of the library with converted chapters.
+type series struct {
+	library.Series
+	Title string
+	Cover string // series cover path relative to the library, if any
+	Books []library.Book
+}
+
+// book is a converted chapter listed in acquisition feeds.
+type book struct {
+	library.Book
+	Series *series
+}
+
+// New returns the handler of the OPDS catalog, OPDS 1.2 feeds under /opds
+// and OPDS 2.0 documents under /opds/v2.
+func New(opt Option) http.Handler {
+	c := &catalog{opt: opt}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /opds", c.root)
+	mux.HandleFunc("GET /opds/series", c.listSeries)
+	mux.HandleFunc("GET /opds/series/{site}/{id}", c.getSeries)
+	mux.HandleFunc("GET /opds/latest", c.latest)
+	mux.HandleFunc("GET /opds/v2", c.root)
+	mux.HandleFunc("GET /opds/v2/series", c.listSeries)
+	mux.HandleFunc("GET /opds/v2/series/{site}/{id}", c.getSeries)
+	mux.HandleFunc("GET /opds/v2/latest", c.latest)
+	return mux
+}
+
+func (c *catalog) root(w http.ResponseWriter, r *http.Request) {
+	all, err := c.series()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	base := basePath(r)
+	last := updated(all)
+	f := newFeed(r, "Library", navigationType, last)
+	f.Links = append(f.Links, link{Rel: relNew, Href: base + "/latest", Type: acquisitionType})
+	f.Entries = []entry{
+		navigationEntry("urn:comic-crawler:series", "All series", "Downloaded series", base+"/series", navigationType, last),
+		navigationEntry("urn:comic-crawler:latest", "Latest additions", "Most recently converted chapters", base+"/latest", acquisitionType, last),
+	}
+	c.write(w, r, f)
+}
+
+func (c *catalog) listSeries(w http.ResponseWriter, r *http.Request) {
+	all, err := c.series()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	base := basePath(r)
+	f := newFeed(r, "All series", navigationType, updated(all))
+	for _, s := range all {
+		e := navigationEntry(seriesId(s.Site, s.Id), s.Title, plural(len(s.Books), "chapter"), base+"/series/"+library.EscapePath(s.Site, s.Id), acquisitionType, s.updated())
+		e.Links = append(e.Links, c.images(s)...)
+		f.Entries = append(f.Entries, e)
+	}
+	c.write(w, r, f)
+}
+
+func (c *catalog) getSeries(w http.ResponseWriter, r *http.Request) {
+	site, id := r.PathValue("site"), r.PathValue("id")
+	for _, name := range []string{site, id} {
+		if err := library.ValidName(name); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	s, err := c.load(library.Series{Site: site, Id: id, Path: filepath.Join(c.opt.Root, site, id)})
+	if os.IsNotExist(err) || (err == nil && len(s.Books) == 0) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	f := newFeed(r, s.Title, acquisitionType, s.updated())
+	for _, b := range s.Books {
+		f.Entries = append(f.Entries, c.bookEntry(book{b, s}))
+	}
+	c.write(w, r, f)
+}
+
+func (c *catalog) latest(w http.ResponseWriter, r *http.Request) {
+	all, err := c.series()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	books := make([]book, 0)
+	for _, s := range all {
+		for _, b := range s.Books {
+			books = append(books, book{b, s})
+		}
+	}
+	sort.SliceStable(books, func(i, j int) bool {
+		return books[i].Modified.After(books[j].Modified)
+	})
+	books = books[:min(len(books), latestLimit)]
+
+	f := newFeed(r, "Latest additions", acquisitionType, updated(all))
+	for _, b := range books {
+		f.Entries = append(f.Entries, c.bookEntry(b))
+	}
+	c.write(w, r, f)
+}
+
+// series returns the comics of the library with converted chapters.
+func (c *catalog) series() ([]*series, error) {
+	items, err := library.ListSeries(c.opt.Root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	res := make([]*series, 0, len(items))
+	for _, item := range items {
+		s, err := c.load(item)
+		if err != nil {
+			log.Warnf("Failed to list books of %s: %v", item.Path, err)
+			continue
+		}
+		if len(s.Books) > 0 {
+			res = append(res, s)
+		}
+	}
+	return res, nil
+}
+
+func (c *catalog) load(item library.Series) (*series, error) {
+	books, err := library.Books(item.Path)
+	if err != nil {
+		return nil, err
+	}
+	s := &series{Series: item, Title: c.opt.Titles[item.Site+"/"+item.Id], Books: books}
+	if s.Title == "" {
+		s.Title = item.Id
+	}
+	if _, err := os.Stat(filepath.Join(item.Path, cover.SeriesFile)); err == nil {
+		s.Cover = library.EscapePath(item.Site, item.Id, cover.SeriesFile)
+	}
+	return s, nil
+}
+
+func (s *series) updated() time.Time {
+	var t time.Time
+	for _, b := range s.Books {
+		if b.Modified.After(t) {
+			t = b.Modified
+		}
+	}
+	return t
+}
+
+func updated(all []*series) time.Time {
+	var t time.Time
+	for _, s := range all {
+		if u := s.updated(); u.After(t) {
+			t = u
+		}
+	}
+	return t
+}
+
+func (c *catalog) images(s *series) []link {
+	if s.Cover == "" {
+		return nil
+	}
+	return []link{
+		{Rel: relImage, Href: c.opt.FilesPath + s.Cover, Type: "image/jpeg"},
+		{Rel: relThumbnail, Href: c.opt.ThumbsPath + s.Cover, Type: "image/jpeg"},
+	}
+}
+
+func (c *catalog) bookEntry(b book) entry {
+	e := entry{
+		Id:      bookId(b.Series.Site, b.Series.Id, b.Chapter),
+		Title:   b.Chapter,
+		Updated: formatTime(b.Modified),
+		Series:  b.Series.Title,
+		Links:   c.images(b.Series),
+	}
+	if number, ok, _, _, _ := crawler.ParseChapterName(b.Chapter); ok {
+		e.Position = number
+	}
+	for _, f := range b.Files {
+		e.Links = append(e.Links, link{
+			Rel:    relAcquisition,
+			Href:   c.opt.FilesPath + library.EscapePath(b.Series.Site, b.Series.Id, f.Format.Name, filepath.Base(f.Path)),
+			Type:   f.Format.MediaType,
+			Length: f.Size,
+		})
+	}
+	return e
+}
+
+// write encodes a feed as OPDS 2.0 under /opds/v2, as OPDS 1.2 otherwise.
+func (c *catalog) write(w http.ResponseWriter, r *http.Request, f feed) {
+	if isV2(r) {
+		w.Header().Set("Content-Type", jsonType)
+		if err := json.NewEncoder(w).Encode(f.v2()); err != nil {
+			log.Errorf("Failed to write feed: %v", err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", f.kind)
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(f); err != nil {
+		log.Errorf("Failed to write feed: %v", err)
+	}
+}
+
+func isV2(r *http.Request) bool {
+	return r.URL.Path == "/opds/v2" || strings.HasPrefix(r.URL.Path, "/opds/v2/")
+}
+
+// basePath returns the root of the catalog version serving the request.
+func basePath(r *http.Request) string {
+	if isV2(r) {
+		return "/opds/v2"
+	}
+	return "/opds"
+}
+
+func seriesId(site, id string) string {
+	return "urn:comic-crawler:" + library.EscapePath(site, id)
+}
+
+// bookId is the identifier of a chapter in the feeds and in its EPUB, so
+// readers match downloaded books with the catalog.
+func bookId(site, id, chapter string) string {
+	return "urn:uuid:" + epub.Identifier(site, id, chapter)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,180 @@
+package opds
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"comic-crawler/service/epub"
+)
+
+func newTestCatalog(t *testing.T) *httptest.Server {
+	t.Helper()
+	root := t.TempDir()
+	now := time.Now()
+	files := []struct {
+		path string
+		age  time.Duration
+	}{
+		{"nettruyen/42/epub/Chương 1.epub", 3 * time.Hour},
+		{"nettruyen/42/cbz/Chương 1.cbz", 3 * time.Hour},
+		{"nettruyen/42/epub/Chương 2.epub", time.Hour},
+		{"nettruyen/42/cover.jpg", 0},
+		{"qqtruyen/7/pdf/Oneshot.pdf", 2 * time.Hour},
+		{"qqtruyen/8/Chương 1/1.jpg", 0}, // not converted
+	}
+	for _, f := range files {
+		path := filepath.Join(root, filepath.FromSlash(f.path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-f.age), now.Add(-f.age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := httptest.NewServer(New(Option{
+		Root:       root,
+		Titles:     map[string]string{"nettruyen/42": "Conan"},
+		FilesPath:  "/files/",
+		ThumbsPath: "/thumbs/",
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(t *testing.T, url string) (*http.Response, []byte) {
+	t.Helper()
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, body
+}
+
+func getFeed(t *testing.T, url, kind string) feed {
+	t.Helper()
+	res, body := get(t, url)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("%s: status %d", url, res.StatusCode)
+	}
+	if got := res.Header.Get("Content-Type"); got != kind {
+		t.Errorf("%s: content type %s, want %s", url, got, kind)
+	}
+	var f feed
+	if err := xml.Unmarshal(body, &f); err != nil {
+		t.Fatalf("%s: %v\n%s", url, err, body)
+	}
+	return f
+}
+
+func entryTitles(f feed) []string {
+	titles := make([]string, 0, len(f.Entries))
+	for _, e := range f.Entries {
+		titles = append(titles, e.Title)
+	}
+	return titles
+}
+
+func TestNavigation(t *testing.T) {
+	srv := newTestCatalog(t)
+
+	root := getFeed(t, srv.URL+"/opds", navigationType)
+	if got := strings.Join(entryTitles(root), ","); got != "All series,Latest additions" {
+		t.Errorf("root entries: %s", got)
+	}
+
+	all := getFeed(t, srv.URL+"/opds/series", navigationType)
+	if got := strings.Join(entryTitles(all), ","); got != "Conan,7" {
+		t.Fatalf("series entries: %s", got)
+	}
+	links := all.Entries[0].Links
+	if links[0].Rel != "subsection" || links[0].Href != "/opds/series/nettruyen/42" || links[0].Type != acquisitionType {
+		t.Errorf("series link: %+v", links[0])
+	}
+	if len(links) != 3 || links[2].Rel != relThumbnail || links[2].Href != "/thumbs/nettruyen/42/cover.jpg" {
+		t.Errorf("series images: %+v", links)
+	}
+
+	if res, _ := get(t, srv.URL+"/opds/series/nettruyen/43"); res.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown series: status %d", res.StatusCode)
+	}
+}
+
+func TestAcquisition(t *testing.T) {
+	srv := newTestCatalog(t)
+
+	series := getFeed(t, srv.URL+"/opds/series/nettruyen/42", acquisitionType)
+	if got := strings.Join(entryTitles(series), ","); got != "Chương 1,Chương 2" {
+		t.Fatalf("chapters: %s", got)
+	}
+	var acquisitions []link
+	for _, l := range series.Entries[0].Links {
+		if l.Rel == relAcquisition {
+			acquisitions = append(acquisitions, l)
+		}
+	}
+	want := []link{
+		{Rel: relAcquisition, Href: "/files/nettruyen/42/epub/Ch%C6%B0%C6%A1ng%201.epub", Type: "application/epub+zip", Length: 4},
+		{Rel: relAcquisition, Href: "/files/nettruyen/42/cbz/Ch%C6%B0%C6%A1ng%201.cbz", Type: "application/vnd.comicbook+zip", Length: 4},
+	}
+	if len(acquisitions) != len(want) || acquisitions[0] != want[0] || acquisitions[1] != want[1] {
+		t.Errorf("acquisition links: got %+v, want %+v", acquisitions, want)
+	}
+	// The EPUB of the chapter has the same identifier
+	if got, want := series.Entries[0].Id, "urn:uuid:"+epub.Identifier("nettruyen", "42", "Chương 1"); got != want {
+		t.Errorf("book id: got %s, want %s", got, want)
+	}
+
+	latest := getFeed(t, srv.URL+"/opds/latest", acquisitionType)
+	if got := strings.Join(entryTitles(latest), ","); got != "Chương 2,Oneshot,Chương 1" {
+		t.Errorf("latest: %s", got)
+	}
+}
+
+func TestV2(t *testing.T) {
+	srv := newTestCatalog(t)
+
+	res, body := get(t, srv.URL+"/opds/v2")
+	if res.Header.Get("Content-Type") != jsonType {
+		t.Errorf("content type %s", res.Header.Get("Content-Type"))
+	}
+	var root feedV2
+	if err := json.Unmarshal(body, &root); err != nil {
+		t.Fatal(err)
+	}
+	if len(root.Navigation) != 2 || root.Navigation[0].Href != "/opds/v2/series" || root.Navigation[0].Type != jsonType {
+		t.Errorf("navigation: %+v", root.Navigation)
+	}
+
+	_, body = get(t, srv.URL+"/opds/v2/series/nettruyen/42")
+	var series feedV2
+	if err := json.Unmarshal(body, &series); err != nil {
+		t.Fatal(err)
+	}
+	if len(series.Publications) != 2 || series.Metadata.NumberOfItems != 2 {
+		t.Fatalf("publications: %s", body)
+	}
+	p := series.Publications[1]
+	if p.Metadata.Title != "Chương 2" || p.Metadata.BelongsTo == nil || p.Metadata.BelongsTo.Series[0].Name != "Conan" ||
+		*p.Metadata.BelongsTo.Series[0].Position != 2 {
+		t.Errorf("publication metadata: %+v", p.Metadata)
+	}
+	if len(p.Links) != 1 || p.Links[0].Type != "application/epub+zip" || len(p.Images) != 1 {
+		t.Errorf("publication links: %+v, images %+v", p.Links, p.Images)
+	}
+}
@@ -0,0 +1,137 @@
+package pathname
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"comic-crawler/service/library"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NamesFile records the folder name of each chapter of a comic by the
+// chapter name of the site.
+const NamesFile = "names.json"
+
+// MaxLength is the length in bytes names are cut to, leaving room for
+// extensions and collision suffixes under the 255 bytes limit of most file
+// systems.
+const MaxLength = 200
+
+// Device names Windows reserves with any extension
+var reserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Clean makes name a single path element valid on Linux, macOS, Windows and
+// exFAT e-readers: unicode NFC, characters reserved on Windows become _,
+// leading dots, which hide files, and trailing dots and spaces are dropped,
+// device names get a _ suffix and long names are cut to MaxLength bytes.
+func Clean(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, norm.NFC.String(name))
+	name = strings.TrimSpace(name)
+	if len(name) > MaxLength {
+		cut := MaxLength
+		for cut > 0 && !utf8.RuneStart(name[cut]) {
+			cut--
+		}
+		name = name[:cut]
+	}
+	name = strings.TrimLeft(strings.TrimRight(name, ". "), ". ")
+	base, _, _ := strings.Cut(name, ".")
+	if reserved[strings.ToUpper(strings.TrimSpace(base))] {
+		name += "_"
+	}
+	if name == "" {
+		return "_"
+	}
+	return name
+}
+
+// Names maps the chapter names of a site to the folder names of a comic.
+type Names struct {
+	dir     string
+	mu      sync.Mutex
+	folders map[string]string
+}
+
+// LoadNames reads the chapter names recorded in the comic folder dir, none
+// if the comic was never crawled.
+func LoadNames(dir string) (*Names, error) {
+	n := &Names{dir: dir, folders: make(map[string]string)}
+	path := filepath.Join(dir, NamesFile)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return n, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &n.folders); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return n, nil
+}
+
+// Folder returns the folder name of a chapter, recording a new one when the
+// chapter is seen for the first time. Names already used by another chapter,
+// compared case-insensitively like Windows and exFAT do, and names of output
+// folders get a (2), (3)... suffix. Chapters crawled before names were
+// recorded keep their folder when its name is a valid chapter folder.
+func (n *Names) Folder(chapter string) (string, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if folder, ok := n.folders[chapter]; ok {
+		return folder, nil
+	}
+
+	used := make(map[string]bool, len(n.folders))
+	for _, folder := range n.folders {
+		used[strings.ToLower(folder)] = true
+	}
+	folder := Clean(chapter)
+	if library.ValidName(chapter) == nil && library.IsChapterName(chapter) {
+		if info, err := os.Stat(filepath.Join(n.dir, chapter)); err == nil && info.IsDir() {
+			folder = chapter
+		}
+	}
+	// Output folders such as epub are not chapters
+	for i := 2; used[strings.ToLower(folder)] || !library.IsChapterName(folder); i++ {
+		folder = fmt.Sprintf("%s (%d)", Clean(chapter), i)
+	}
+	n.folders[chapter] = folder
+
+	data, err := json.MarshalIndent(n.folders, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(n.dir, 0755); err != nil {
+		return "", err
+	}
+	return folder, os.WriteFile(filepath.Join(n.dir, NamesFile), data, 0644)
+}
+
+// Chapter returns the chapter name a folder was created for, the folder name
+// itself if it was not recorded.
+func (n *Names) Chapter(folder string) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for chapter, f := range n.folders {
+		if f == folder {
+			return chapter
+		}
+	}
+	return folder
+}
@@ -0,0 +1,83 @@
+package pathname
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestClean(t *testing.T) {
+	tests := map[string]string{
+		"Chapter 1":               "Chapter 1",
+		"Chapter 1/2: Part?":      "Chapter 1_2_ Part_",
+		"Re:Zero":                 "Re_Zero",
+		"Who?. ":                  "Who_",
+		"...":                     "_",
+		".5 Extra":                "5 Extra",
+		"":                        "_",
+		"Thám tử\tConan":          "Thám tử_Conan",
+		"Cha\u0302u":              "Châu", // decomposed â
+		"con":                     "con_",
+		"NUL.txt":                 "NUL.txt_",
+		"Console":                 "Console",
+		" Chương 5 - Kết thúc.. ": "Chương 5 - Kết thúc",
+	}
+	for name, want := range tests {
+		if got := Clean(name); got != want {
+			t.Errorf("%q: got %q, want %q", name, got, want)
+		}
+	}
+
+	long := Clean(strings.Repeat("ừ", 100))
+	if len(long) > MaxLength || !utf8.ValidString(long) {
+		t.Errorf("long name: %d bytes, valid %v", len(long), utf8.ValidString(long))
+	}
+}
+
+func TestNames(t *testing.T) {
+	dir := t.TempDir()
+	for _, folder := range []string{"Chapter 0", "epub", ".cache"} {
+		if err := os.Mkdir(filepath.Join(dir, folder), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names, err := LoadNames(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		chapter string
+		want    string
+	}{
+		{"Chapter 1?", "Chapter 1_"},
+		{"Chapter 1*", "Chapter 1_ (2)"},
+		{"CHAPTER 1?", "CHAPTER 1_ (3)"},
+		{"Chapter 1?", "Chapter 1_"},
+		{"Chapter 0", "Chapter 0"}, // crawled before names were recorded
+		{"EPUB", "EPUB (2)"},       // output folder
+		{".cache", "cache"},
+		{"..", "_"},
+	}
+	for _, tt := range tests {
+		got, err := names.Folder(tt.chapter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.chapter, got, tt.want)
+		}
+	}
+
+	names, err = LoadNames(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names.Chapter("Chapter 1_ (2)"); got != "Chapter 1*" {
+		t.Errorf("reloaded chapter: got %q", got)
+	}
+	if got := names.Chapter("Extra"); got != "Extra" {
+		t.Errorf("unrecorded folder: got %q", got)
+	}
+}
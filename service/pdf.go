@@ -1,44 +1,67 @@
 package service
 
 import (
+	"bytes"
 	"fmt"
-	"os"
+	"image"
+
+	"comic-crawler/service/imaging"
 
 	"github.com/go-pdf/fpdf"
+	"github.com/vukyn/kuery/file"
 )
 
-func ImagesToPDF(folderPath string, filePath, fileName string) error {
-	// Read all files in folder
-	files, err := os.ReadDir(folderPath)
+type PdfOption struct {
+	Pipeline *imaging.Pipeline
+	// Cover shown as the first page, if any
+	Cover []byte
+	// Pages detected as ads or duplicates, by file name
+	SkipPages map[string]bool
+}
+
+// Page width in mm, page height follows the image ratio
+const pdfPageWidth = 210.0
+
+func ImagesToPDF(folderPath string, filePath, fileName string, opt PdfOption) error {
+	// Process pages in memory
+	sources, err := imaging.SourcePages(folderPath, opt.SkipPages)
+	if err != nil {
+		return err
+	}
+	pages, err := opt.Pipeline.Run(sources)
 	if err != nil {
 		return err
 	}
 
 	pdf := fpdf.New("P", "mm", "A4", "")
-	for _, f := range files {
-		info, err := f.Info()
+	pdf.SetAutoPageBreak(false, 0)
+	if len(opt.Cover) > 0 {
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(opt.Cover))
 		if err != nil {
-			return err // in case of file removed or renamed
+			return fmt.Errorf("cover: %w", err)
 		}
-		if info.IsDir() {
-			continue
-		}
-		pdf.AddPage()
-
-		imgPath := fmt.Sprintf("%s/%s", folderPath, f.Name())
-
-		// Get image size
-		// img, err := imgio.Open(imgPath)
-		// if err != nil {
-		// 	return err
-		// }
-		// w, h := img.Bounds().Dx(), img.Bounds().Dy()
-
-		// Render image to PDF
-		opts := fpdf.ImageOptions{ImageType: "JPG", ReadDpi: false}
-		pdf.ImageOptions(imgPath, 0, 0, 0, 0, false, opts, 0, "")
+		addImagePage(pdf, "cover", imaging.Output{Data: opt.Cover, Width: cfg.Width, Height: cfg.Height})
+	}
+	for i, page := range pages {
+		addImagePage(pdf, fmt.Sprintf("part%d", i+1), page)
 	}
 
-	output := fmt.Sprintf("%s/%s.pdf", filePath, fileName)
+	if err := file.CreateFilePath(fmt.Sprintf("%s/pdf/", filePath)); err != nil {
+		return err
+	}
+	output := fmt.Sprintf("%s/pdf/%s.pdf", filePath, fileName)
 	return pdf.OutputFileAndClose(output)
 }
+
+// addImagePage renders an image on its own page, one page per image.
+func addImagePage(pdf *fpdf.Fpdf, name string, page imaging.Output) {
+	imageType := "JPG"
+	if page.MediaType() == "image/png" {
+		imageType = "PNG"
+	}
+	opts := fpdf.ImageOptions{ImageType: imageType, ReadDpi: false}
+	pdf.RegisterImageOptionsReader(name, opts, bytes.NewReader(page.Data))
+	pageHeight := pdfPageWidth * float64(page.Height) / float64(page.Width)
+	pdf.AddPageFormat("P", fpdf.SizeType{Wd: pdfPageWidth, Ht: pageHeight})
+	pdf.ImageOptions(name, 0, 0, pdfPageWidth, pageHeight, false, opts, 0, "")
+}
@@ -0,0 +1,257 @@
+package server
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"image"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"comic-crawler/service/cover"
+	"comic-crawler/service/imaging"
+	"comic-crawler/service/library"
+	"comic-crawler/service/opds"
+
+	"github.com/anthonynsimon/bild/transform"
+	"github.com/vukyn/kuery/log"
+)
+
+//go:embed static
+var static embed.FS
+
+// Bounds of the width of thumbnails
+const (
+	defaultThumbWidth = 300
+	maxThumbWidth     = 1600
+)
+
+type Option struct {
+	Root string // library folder, out/<site>/<id>/<chapter>
+	// Series titles by "<site>/<id>", the id is shown otherwise
+	Titles map[string]string
+}
+
+type server struct {
+	root   string
+	titles map[string]string
+}
+
+type seriesResponse struct {
+	Site     string            `json:"site"`
+	Id       string            `json:"id"`
+	Title    string            `json:"title"`
+	Cover    string            `json:"cover,omitempty"`
+	Chapters []chapterResponse `json:"chapters"`
+}
+
+type chapterResponse struct {
+	Name  string         `json:"name"`
+	Prev  string         `json:"prev,omitempty"`
+	Next  string         `json:"next,omitempty"`
+	Pages []pageResponse `json:"pages,omitempty"`
+}
+
+type pageResponse struct {
+	Name      string `json:"name"`
+	Url       string `json:"url"`
+	Thumbnail string `json:"thumbnail"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+}
+
+// New returns the handler of the library server: a JSON API, an OPDS catalog,
+// the downloaded files with range requests, resized thumbnails and a web
+// reader.
+func New(opt Option) http.Handler {
+	s := &server{root: opt.Root, titles: opt.Titles}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/series", s.listSeries)
+	mux.HandleFunc("GET /api/series/{site}/{id}", s.getSeries)
+	mux.HandleFunc("GET /api/series/{site}/{id}/chapters/{chapter}", s.getChapter)
+	mux.Handle("GET /files/", http.StripPrefix("/files/", http.FileServer(http.Dir(opt.Root))))
+	mux.HandleFunc("GET /thumbs/{path...}", s.thumbnail)
+	catalog := opds.New(opds.Option{Root: opt.Root, Titles: opt.Titles, FilesPath: "/files/", ThumbsPath: "/thumbs/"})
+	mux.Handle("GET /opds", catalog)
+	mux.Handle("GET /opds/", catalog)
+	sub, _ := fs.Sub(static, "static")
+	mux.Handle("GET /", http.FileServer(http.FS(sub)))
+	return mux
+}
+
+func (s *server) listSeries(w http.ResponseWriter, r *http.Request) {
+	series, err := library.ListSeries(s.root)
+	if err != nil && !os.IsNotExist(err) {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res := make([]seriesResponse, 0, len(series))
+	for _, item := range series {
+		res = append(res, s.series(item, false))
+	}
+	writeJSON(w, res)
+}
+
+func (s *server) getSeries(w http.ResponseWriter, r *http.Request) {
+	item, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, s.series(item, true))
+}
+
+func (s *server) getChapter(w http.ResponseWriter, r *http.Request) {
+	item, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	name := r.PathValue("chapter")
+	if err := library.ValidName(name); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	chapters, err := library.Chapters(item.Path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res := chapterResponse{Name: name, Pages: make([]pageResponse, 0)}
+	found := false
+	for i, chapter := range chapters {
+		if chapter != name {
+			continue
+		}
+		found = true
+		if i > 0 {
+			res.Prev = chapters[i-1]
+		}
+		if i < len(chapters)-1 {
+			res.Next = chapters[i+1]
+		}
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, os.ErrNotExist)
+		return
+	}
+
+	pages, err := imaging.SourcePages(filepath.Join(item.Path, name), nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	for _, page := range pages {
+		rel := library.EscapePath(item.Site, item.Id, name, filepath.Base(page))
+		p := pageResponse{Name: filepath.Base(page), Url: "/files/" + rel, Thumbnail: "/thumbs/" + rel}
+		if f, err := os.Open(page); err == nil {
+			if cfg, _, err := image.DecodeConfig(f); err == nil {
+				p.Width, p.Height = cfg.Width, cfg.Height
+			}
+			f.Close()
+		}
+		res.Pages = append(res.Pages, p)
+	}
+	writeJSON(w, res)
+}
+
+// lookup returns the series named by the request path.
+func (s *server) lookup(w http.ResponseWriter, r *http.Request) (library.Series, bool) {
+	site, id := r.PathValue("site"), r.PathValue("id")
+	for _, name := range []string{site, id} {
+		if err := library.ValidName(name); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return library.Series{}, false
+		}
+	}
+	item := library.Series{Site: site, Id: id, Path: filepath.Join(s.root, site, id)}
+	if info, err := os.Stat(item.Path); err != nil || !info.IsDir() {
+		writeError(w, http.StatusNotFound, os.ErrNotExist)
+		return library.Series{}, false
+	}
+	return item, true
+}
+
+func (s *server) series(item library.Series, withChapters bool) seriesResponse {
+	res := seriesResponse{Site: item.Site, Id: item.Id, Title: s.titles[item.Site+"/"+item.Id], Chapters: make([]chapterResponse, 0)}
+	if res.Title == "" {
+		res.Title = item.Id
+	}
+	if _, err := os.Stat(filepath.Join(item.Path, cover.SeriesFile)); err == nil {
+		res.Cover = "/thumbs/" + library.EscapePath(item.Site, item.Id, cover.SeriesFile)
+	}
+	if withChapters {
+		chapters, err := library.Chapters(item.Path)
+		if err != nil {
+			log.Warnf("Failed to list chapters of %s: %v", item.Path, err)
+		}
+		for _, chapter := range chapters {
+			res.Chapters = append(res.Chapters, chapterResponse{Name: chapter})
+		}
+	}
+	return res
+}
+
+// thumbnail serves an image of the library resized to the width query
+// parameter, keeping its ratio.
+func (s *server) thumbnail(w http.ResponseWriter, r *http.Request) {
+	rel := path.Clean("/" + r.PathValue("path"))
+	src := filepath.Join(s.root, filepath.FromSlash(rel))
+	info, err := os.Stat(src)
+	if err != nil || info.IsDir() {
+		writeError(w, http.StatusNotFound, os.ErrNotExist)
+		return
+	}
+	width := defaultThumbWidth
+	if v := r.URL.Query().Get("width"); v != "" {
+		if width, err = strconv.Atoi(v); err != nil || width <= 0 {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		width = min(width, maxThumbWidth)
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		writeError(w, http.StatusUnsupportedMediaType, err)
+		return
+	}
+	b := img.Bounds()
+	if b.Dx() > width {
+		img = transform.Resize(img, width, max(1, b.Dy()*width/b.Dx()), transform.Linear)
+	}
+	data, err := imaging.Encode(img, imaging.EncodeJPEG, 80)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "max-age=86400")
+	http.ServeContent(w, r, "", info.ModTime().Truncate(time.Second), bytes.NewReader(data))
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("Failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	msg := http.StatusText(status)
+	if err != nil {
+		msg = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	root := t.TempDir()
+	comic := filepath.Join(root, "nettruyen", "42")
+	for _, chapter := range []string{"Chương 2", "Chương 1"} {
+		dir := filepath.Join(comic, chapter)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"1.jpg", "2.jpg"} {
+			f, err := os.Create(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := jpeg.Encode(f, image.NewGray(image.Rect(0, 0, 600, 900)), nil); err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+		}
+	}
+	srv := httptest.NewServer(New(Option{Root: root, Titles: map[string]string{"nettruyen/42": "Conan"}}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func getJSON(t *testing.T, url string, v any) int {
+	t.Helper()
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if v != nil && res.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return res.StatusCode
+}
+
+func TestAPI(t *testing.T) {
+	srv := newTestServer(t)
+
+	var series []seriesResponse
+	getJSON(t, srv.URL+"/api/series", &series)
+	if len(series) != 1 || series[0].Title != "Conan" || series[0].Site != "nettruyen" || series[0].Id != "42" {
+		t.Fatalf("got series %+v", series)
+	}
+
+	var detail seriesResponse
+	getJSON(t, srv.URL+"/api/series/nettruyen/42", &detail)
+	if len(detail.Chapters) != 2 || detail.Chapters[0].Name != "Chương 1" {
+		t.Fatalf("got chapters %+v", detail.Chapters)
+	}
+
+	var chapter chapterResponse
+	getJSON(t, srv.URL+"/api/series/nettruyen/42/chapters/Ch%C6%B0%C6%A1ng%201", &chapter)
+	if chapter.Next != "Chương 2" || chapter.Prev != "" || len(chapter.Pages) != 2 {
+		t.Fatalf("got chapter %+v", chapter)
+	}
+	page := chapter.Pages[0]
+	if page.Url != "/files/nettruyen/42/Ch%C6%B0%C6%A1ng%201/1.jpg" || page.Width != 600 || page.Height != 900 {
+		t.Errorf("got page %+v", page)
+	}
+
+	for path, status := range map[string]int{
+		"/api/series/nettruyen/43":                   http.StatusNotFound,
+		"/api/series/nettruyen/42/chapters/Chương 9": http.StatusNotFound,
+		"/api/series/nettruyen/42/chapters/a%2Fb":    http.StatusBadRequest,
+	} {
+		if got := getJSON(t, srv.URL+path, nil); got != status {
+			t.Errorf("%s: got %d, want %d", path, got, status)
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	srv := newTestServer(t)
+	var chapter chapterResponse
+	getJSON(t, srv.URL+"/api/series/nettruyen/42/chapters/Ch%C6%B0%C6%A1ng%201", &chapter)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+chapter.Pages[0].Url, nil)
+	req.Header.Set("Range", "bytes=0-9")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusPartialContent || res.ContentLength != 10 {
+		t.Errorf("range request: got %d with %d bytes", res.StatusCode, res.ContentLength)
+	}
+
+	res, err = http.Get(srv.URL + chapter.Pages[0].Thumbnail + "?width=200")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	img, err := jpeg.Decode(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 200 || b.Dy() != 300 {
+		t.Errorf("thumbnail: got %dx%d, want 200x300", b.Dx(), b.Dy())
+	}
+
+	res, err = http.Get(srv.URL + "/opds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || !strings.HasPrefix(res.Header.Get("Content-Type"), "application/atom+xml") {
+		t.Errorf("opds catalog: got %d %s", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+
+	res, err = http.Get(srv.URL + "/thumbs/..%2f..%2fetc/passwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("thumbnail outside the library: got %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,131 @@
+// Chapter reader: scroll or paged mode, keyboard and click navigation.
+(function () {
+	var body = document.body;
+	var pages = Array.prototype.slice.call(document.querySelectorAll("main img"));
+	var counter = document.querySelector(".counter");
+	var prev = document.querySelector("a.prev");
+	var next = document.querySelector("a.next");
+	var rtl = body.dataset.rtl === "true";
+	var current = 0;
+
+	// The chosen mode is kept across chapters when the browser allows storage
+	function stored(key, fallback) {
+		try {
+			return localStorage.getItem(key) || fallback;
+		} catch (e) {
+			return fallback;
+		}
+	}
+	function store(key, value) {
+		try {
+			localStorage.setItem(key, value);
+		} catch (e) {}
+	}
+
+	function paged() {
+		return body.classList.contains("paged");
+	}
+
+	function setMode(mode) {
+		body.classList.remove("scroll", "paged");
+		body.classList.add(mode);
+		store("mode", mode);
+		show(current);
+	}
+
+	function show(i) {
+		current = Math.max(0, Math.min(i, pages.length - 1));
+		pages.forEach(function (page, j) {
+			page.classList.toggle("current", j === current);
+		});
+		if (counter) {
+			counter.textContent = pages.length ? current + 1 + " / " + pages.length : "";
+		}
+		if (paged()) {
+			window.scrollTo(0, 0);
+			location.replace("#" + (current + 1));
+		}
+	}
+
+	function go(link, hash) {
+		if (link) {
+			location.href = link.href + (hash || "");
+		}
+	}
+
+	// Pages move forward past the last page into the next chapter, and back
+	// into the last page of the previous chapter
+	function forward() {
+		if (current < pages.length - 1) {
+			show(current + 1);
+		} else {
+			go(next);
+		}
+	}
+	function backward() {
+		if (current > 0) {
+			show(current - 1);
+		} else {
+			go(prev, "#last");
+		}
+	}
+
+	document.addEventListener("keydown", function (e) {
+		if (e.altKey || e.ctrlKey || e.metaKey) {
+			return;
+		}
+		switch (e.key) {
+			case "ArrowRight":
+			case "ArrowLeft":
+				var ahead = (e.key === "ArrowRight") !== rtl;
+				if (paged()) {
+					ahead ? forward() : backward();
+				} else {
+					go(ahead ? next : prev);
+				}
+				break;
+			case " ":
+				if (!paged()) {
+					return;
+				}
+				e.shiftKey ? backward() : forward();
+				break;
+			case "n":
+				go(next);
+				break;
+			case "p":
+				go(prev);
+				break;
+			case "m":
+				setMode(paged() ? "scroll" : "paged");
+				break;
+			default:
+				return;
+		}
+		e.preventDefault();
+	});
+
+	pages.forEach(function (page) {
+		page.addEventListener("click", function (e) {
+			if (!paged()) {
+				return;
+			}
+			var rect = page.getBoundingClientRect();
+			var right = e.clientX > rect.left + rect.width / 2;
+			right !== rtl ? forward() : backward();
+		});
+	});
+
+	Array.prototype.forEach.call(document.querySelectorAll("button.mode"), function (button) {
+		button.addEventListener("click", function () {
+			setMode(paged() ? "scroll" : "paged");
+		});
+	});
+
+	var hash = location.hash.slice(1);
+	current = hash === "last" ? pages.length - 1 : Math.max(0, parseInt(hash, 10) - 1 || 0);
+	setMode(stored("mode", body.dataset.mode));
+	if (!paged() && hash === "last" && pages.length) {
+		pages[current].scrollIntoView();
+	}
+})();
@@ -0,0 +1,152 @@
+package web
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"comic-crawler/service/imaging"
+
+	"github.com/vukyn/kuery/file"
+)
+
+//go:embed template
+var templates embed.FS
+
+var tmpl = template.Must(template.New("").Funcs(template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+}).ParseFS(templates, "template/*.html"))
+
+// Static files shared by every page of the site
+var static = []string{"style.css", "reader.js"}
+
+// Mode is how chapter pages are read, readers can switch it on the page.
+type Mode string
+
+const (
+	ModeScroll Mode = "scroll" // pages stacked vertically
+	ModePaged  Mode = "paged"  // one page at a time
+)
+
+func ParseMode(mode string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(mode))); m {
+	case "":
+		return ModeScroll, nil
+	case ModeScroll, ModePaged:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown html mode %q, use one of scroll, paged", mode)
+	}
+}
+
+type ChapterOption struct {
+	Title    string // series title
+	Language string
+	RTL      bool
+	Mode     Mode
+	// Neighbouring chapters in reading order, empty at the ends
+	Prev, Next string
+	Pipeline   *imaging.Pipeline
+	// Pages detected as ads or duplicates, by file name
+	SkipPages map[string]bool
+}
+
+type page struct {
+	Src    string
+	Width  int
+	Height int
+}
+
+// ChapterToHTML writes a reader page for a chapter to html/<chapter>/index.html
+// under filePath, next to its processed images.
+func ChapterToHTML(folderPath, filePath, chapter string, opt ChapterOption) error {
+	sources, err := imaging.SourcePages(folderPath, opt.SkipPages)
+	if err != nil {
+		return err
+	}
+	outputs, err := opt.Pipeline.Run(sources)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(filePath, "html", chapter)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := file.CreateFilePath(dir + "/"); err != nil {
+		return err
+	}
+	pages := make([]page, 0, len(outputs))
+	for i, output := range outputs {
+		name := fmt.Sprintf("%03d%s", i+1, imaging.Extension(output.MediaType()))
+		if err := os.WriteFile(filepath.Join(dir, name), output.Data, 0644); err != nil {
+			return err
+		}
+		pages = append(pages, page{Src: name, Width: output.Width, Height: output.Height})
+	}
+
+	return render(filepath.Join(dir, "index.html"), "chapter.html", map[string]any{
+		"Title":    opt.Title,
+		"Chapter":  chapter,
+		"Language": opt.Language,
+		"RTL":      opt.RTL,
+		"Mode":     opt.Mode,
+		"Prev":     opt.Prev,
+		"Next":     opt.Next,
+		"Pages":    pages,
+	})
+}
+
+type IndexOption struct {
+	Title    string
+	Author   string
+	Language string
+	Cover    []byte // series cover, if any
+	// Chapters in reading order
+	Chapters []string
+}
+
+// WriteIndex writes the series page listing chapters and the static files of
+// the site to html/ under filePath.
+func WriteIndex(filePath string, opt IndexOption) error {
+	dir := filepath.Join(filePath, "html")
+	if err := file.CreateFilePath(dir + "/"); err != nil {
+		return err
+	}
+	for _, name := range static {
+		data, err := templates.ReadFile("template/" + name)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+
+	cover := ""
+	if len(opt.Cover) > 0 {
+		cover = "cover.jpg"
+		if err := os.WriteFile(filepath.Join(dir, cover), opt.Cover, 0644); err != nil {
+			return err
+		}
+	}
+	return render(filepath.Join(dir, "index.html"), "index.html", map[string]any{
+		"Title":    opt.Title,
+		"Author":   opt.Author,
+		"Language": opt.Language,
+		"Cover":    cover,
+		"Chapters": opt.Chapters,
+	})
+}
+
+func render(output, name string, data any) error {
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return err
+	}
+	return os.WriteFile(output, buf.Bytes(), 0644)
+}
@@ -0,0 +1,92 @@
+package web
+
+import (
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"comic-crawler/service/imaging"
+)
+
+func TestChapterToHTML(t *testing.T) {
+	dir := t.TempDir()
+	chapter := filepath.Join(dir, "Chương 2")
+	if err := os.MkdirAll(chapter, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"1.jpg", "2.jpg"} {
+		f, err := os.Create(filepath.Join(chapter, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := jpeg.Encode(f, image.NewGray(image.Rect(0, 0, 60, 90)), nil); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+
+	err := ChapterToHTML(chapter, dir, "Chương 2", ChapterOption{
+		Title:    "Series <1>",
+		Language: "vi",
+		Mode:     ModePaged,
+		Prev:     "Chương 1",
+		Pipeline: imaging.NewPipeline(imaging.Config{}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "html", "Chương 2", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(data)
+	for _, want := range []string{
+		`<title>Chương 2 - Series &lt;1&gt;</title>`,
+		`data-mode="paged"`,
+		`<img src="001.jpg" width="60" height="90" alt="Page 1" loading="lazy" />`,
+		`<img src="002.jpg" width="60" height="90" alt="Page 2" loading="lazy" />`,
+		`href="../Ch%c6%b0%c6%a1ng%201/index.html"`,
+		`<script src="../reader.js"></script>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("missing %s in:\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, `class="next"`) {
+		t.Error("last chapter links to a next chapter")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "html", "Chương 2", "002.jpg")); err != nil {
+		t.Error(err)
+	}
+
+	if err := WriteIndex(dir, IndexOption{Title: "Series", Chapters: []string{"Chương 1", "Chương 2"}}); err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "html", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `<li><a href="Ch%c6%b0%c6%a1ng%202/index.html">Chương 2</a></li>`) {
+		t.Errorf("missing chapter link in:\n%s", data)
+	}
+	for _, name := range static {
+		if _, err := os.Stat(filepath.Join(dir, "html", name)); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	if m, err := ParseMode(""); err != nil || m != ModeScroll {
+		t.Errorf("empty mode: got %s, %v", m, err)
+	}
+	if m, err := ParseMode("Paged"); err != nil || m != ModePaged {
+		t.Errorf("paged mode: got %s, %v", m, err)
+	}
+	if _, err := ParseMode("book"); err == nil {
+		t.Error("unknown mode should fail")
+	}
+}